@@ -0,0 +1,303 @@
+// Package core builds the OpenTelemetry trace and metric pipelines: OTLP exporters,
+// resource detection and Go runtime metrics. The http and lambda packages are presets on top of it.
+package core
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime/metrics"
+
+	"github.com/idnandre/gobsv/internal/config"
+	"github.com/idnandre/gobsv/internal/exporter"
+	"github.com/idnandre/gobsv/internal/metadata"
+	"github.com/idnandre/gobsv/internal/procfs"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc/credentials"
+)
+
+// New sets up the trace and metric pipelines and returns the Provider owning them.
+// Unless disabled with WithGlobal(false), the providers and propagator are also registered
+// as the OpenTelemetry globals. Every call builds an independent pipeline.
+// An error is returned when the configuration is invalid or an exporter or instrument
+// cannot be created, in which case nothing is registered.
+func New(ctx context.Context, opts ...Option) (*Provider, error) {
+	cfg, err := config.Load(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := newResource(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	exp, err := newOTLPTraceExporter(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize trace exporter: %w", err)
+	}
+
+	expM, err := newOTLPMetricExporter(ctx, cfg)
+	if err != nil {
+		exp.Shutdown(ctx)
+		return nil, fmt.Errorf("failed to initialize metric exporter: %w", err)
+	}
+
+	traceProvider := newTraceProvider(exp, res, cfg)
+	runtimeMetrics := metadata.Select(metrics.All(), cfg.RuntimeMetrics, cfg.RuntimeMetricsInclude, cfg.RuntimeMetricsExclude)
+	// The runtime metrics and histograms share one metrics.Read per collection
+	runtimeSamples := &runtimeReader{}
+	var producers []sdkmetric.Producer
+	if histograms := newRuntimeHistograms(cfg.ServiceName, cfg.RuntimeMetricsNaming, histogramTemporality(cfg.Temporality), runtimeSamples, runtimeMetrics); histograms != nil {
+		producers = append(producers, histograms)
+	}
+
+	meterProvider := newMeterProvider(expM, res, cfg, producers...)
+	if err := addMetricsToOTEL(meterProvider, cfg.ServiceName, cfg.RuntimeMetricsNaming, runtimeSamples, runtimeMetrics); err != nil {
+		traceProvider.Shutdown(ctx)
+		meterProvider.Shutdown(ctx)
+		return nil, fmt.Errorf("failed to register runtime metrics: %w", err)
+	}
+	if cfg.ProcessMetrics {
+		if err := addProcessMetrics(meterProvider, procfs.NewFS("/")); err != nil {
+			traceProvider.Shutdown(ctx)
+			meterProvider.Shutdown(ctx)
+			return nil, fmt.Errorf("failed to register process metrics: %w", err)
+		}
+	}
+
+	p := &Provider{
+		traceProvider: traceProvider,
+		meterProvider: meterProvider,
+		propagator:    cfg.Propagator,
+		config:        cfg,
+	}
+	if cfg.Global {
+		p.SetGlobal()
+	}
+	return p, nil
+}
+
+// OTLP Trace Exporter
+func newOTLPTraceExporter(ctx context.Context, cfg config.Config) (sdktrace.SpanExporter, error) {
+	build := newOTLPHTTPTraceExporter
+	if cfg.Protocol == config.ProtocolGRPC {
+		build = newOTLPGRPCTraceExporter
+	}
+
+	if cfg.HeadersFunc != nil {
+		return exporter.NewSpanExporter(ctx, cfg.Headers, cfg.HeadersFunc, func(ctx context.Context, headers map[string]string) (sdktrace.SpanExporter, error) {
+			return build(ctx, cfg, headers)
+		})
+	}
+	return build(ctx, cfg, cfg.Headers)
+}
+
+func newOTLPHTTPTraceExporter(ctx context.Context, cfg config.Config, headers map[string]string) (sdktrace.SpanExporter, error) {
+	// Update default OTLP reciver endpoint. The exporters read OTEL_EXPORTER_OTLP_* as well,
+	// the resolved settings are always passed so that the options override them
+	compression := otlptracehttp.NoCompression
+	if cfg.Compression == config.CompressionGzip {
+		compression = otlptracehttp.GzipCompression
+	}
+	opts := []otlptracehttp.Option{
+		otlptracehttp.WithEndpoint(cfg.Endpoint),
+		otlptracehttp.WithURLPath(cfg.URLPath + "/v1/traces"),
+		otlptracehttp.WithHeaders(headers),
+		otlptracehttp.WithCompression(compression),
+	}
+
+	// Change default HTTPS -> HTTP unless TLS is configured
+	if cfg.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	} else if cfg.TLSConfig != nil {
+		opts = append(opts, otlptracehttp.WithTLSClientConfig(cfg.TLSConfig))
+	}
+
+	if cfg.Retry != nil {
+		opts = append(opts, otlptracehttp.WithRetry(otlptracehttp.RetryConfig(*cfg.Retry)))
+	}
+
+	return otlptracehttp.New(ctx, opts...)
+}
+
+func newOTLPGRPCTraceExporter(ctx context.Context, cfg config.Config, headers map[string]string) (sdktrace.SpanExporter, error) {
+	// Update default OTLP reciver endpoint. The exporters read OTEL_EXPORTER_OTLP_* as well,
+	// the resolved settings are always passed so that the options override them
+	opts := []otlptracegrpc.Option{
+		otlptracegrpc.WithEndpoint(cfg.Endpoint),
+		otlptracegrpc.WithHeaders(headers),
+	}
+	if compressor := grpcCompressor(cfg, "TRACES"); compressor != "" {
+		opts = append(opts, otlptracegrpc.WithCompressor(compressor))
+	}
+
+	// Change default TLS -> plaintext unless TLS is configured
+	if cfg.Insecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	} else if cfg.TLSConfig != nil {
+		opts = append(opts, otlptracegrpc.WithTLSCredentials(credentials.NewTLS(cfg.TLSConfig)))
+	}
+
+	if cfg.Retry != nil {
+		opts = append(opts, otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig(*cfg.Retry)))
+	}
+
+	return otlptracegrpc.New(ctx, opts...)
+}
+
+// OTLP Metric Exporter
+func newOTLPMetricExporter(ctx context.Context, cfg config.Config) (sdkmetric.Exporter, error) {
+	build := newOTLPHTTPMetricExporter
+	if cfg.Protocol == config.ProtocolGRPC {
+		build = newOTLPGRPCMetricExporter
+	}
+
+	if cfg.HeadersFunc != nil {
+		return exporter.NewMetricExporter(ctx, cfg.Headers, cfg.HeadersFunc, func(ctx context.Context, headers map[string]string) (sdkmetric.Exporter, error) {
+			return build(ctx, cfg, headers)
+		})
+	}
+	return build(ctx, cfg, cfg.Headers)
+}
+
+func newOTLPHTTPMetricExporter(ctx context.Context, cfg config.Config, headers map[string]string) (sdkmetric.Exporter, error) {
+	// Update default OTLP reciver endpoint. The exporters read OTEL_EXPORTER_OTLP_* as well,
+	// the resolved settings are always passed so that the options override them
+	compression := otlpmetrichttp.NoCompression
+	if cfg.Compression == config.CompressionGzip {
+		compression = otlpmetrichttp.GzipCompression
+	}
+	opts := []otlpmetrichttp.Option{
+		otlpmetrichttp.WithEndpoint(cfg.Endpoint),
+		otlpmetrichttp.WithURLPath(cfg.URLPath + "/v1/metrics"),
+		otlpmetrichttp.WithHeaders(headers),
+		otlpmetrichttp.WithCompression(compression),
+	}
+
+	// Change default HTTPS -> HTTP unless TLS is configured
+	if cfg.Insecure {
+		opts = append(opts, otlpmetrichttp.WithInsecure())
+	} else if cfg.TLSConfig != nil {
+		opts = append(opts, otlpmetrichttp.WithTLSClientConfig(cfg.TLSConfig))
+	}
+
+	if cfg.Retry != nil {
+		opts = append(opts, otlpmetrichttp.WithRetry(otlpmetrichttp.RetryConfig(*cfg.Retry)))
+	}
+	opts = append(opts, otlpmetrichttp.WithTemporalitySelector(temporalitySelector(cfg.Temporality)))
+
+	return otlpmetrichttp.New(ctx, opts...)
+}
+
+func newOTLPGRPCMetricExporter(ctx context.Context, cfg config.Config, headers map[string]string) (sdkmetric.Exporter, error) {
+	// Update default OTLP reciver endpoint. The exporters read OTEL_EXPORTER_OTLP_* as well,
+	// the resolved settings are always passed so that the options override them
+	opts := []otlpmetricgrpc.Option{
+		otlpmetricgrpc.WithEndpoint(cfg.Endpoint),
+		otlpmetricgrpc.WithHeaders(headers),
+	}
+	if compressor := grpcCompressor(cfg, "METRICS"); compressor != "" {
+		opts = append(opts, otlpmetricgrpc.WithCompressor(compressor))
+	}
+
+	// Change default TLS -> plaintext unless TLS is configured
+	if cfg.Insecure {
+		opts = append(opts, otlpmetricgrpc.WithInsecure())
+	} else if cfg.TLSConfig != nil {
+		opts = append(opts, otlpmetricgrpc.WithTLSCredentials(credentials.NewTLS(cfg.TLSConfig)))
+	}
+
+	if cfg.Retry != nil {
+		opts = append(opts, otlpmetricgrpc.WithRetry(otlpmetricgrpc.RetryConfig(*cfg.Retry)))
+	}
+	opts = append(opts, otlpmetricgrpc.WithTemporalitySelector(temporalitySelector(cfg.Temporality)))
+
+	return otlpmetricgrpc.New(ctx, opts...)
+}
+
+// grpcCompressor returns the compressor to pass to the gRPC exporters of signal, "" for none.
+// They only support disabling compression through an unknown name, which they log, so it is
+// only passed to override a compression read from the environment.
+func grpcCompressor(cfg config.Config, signal string) string {
+	if cfg.Compression != "" {
+		return cfg.Compression
+	}
+	if os.Getenv("OTEL_EXPORTER_OTLP_COMPRESSION") != "" || os.Getenv("OTEL_EXPORTER_OTLP_"+signal+"_COMPRESSION") != "" {
+		return "none"
+	}
+	return ""
+}
+
+// TracerProvider is an OpenTelemetry TracerProvider.
+// It provides Tracers to instrumentation so it can trace operational flow through a system.
+func newTraceProvider(exp sdktrace.SpanExporter, res *resource.Resource, cfg config.Config) *sdktrace.TracerProvider {
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(res),
+	}
+	if cfg.Sampler != nil {
+		opts = append(opts, sdktrace.WithSampler(cfg.Sampler))
+	}
+
+	return sdktrace.NewTracerProvider(opts...)
+}
+
+func newMeterProvider(exp sdkmetric.Exporter, res *resource.Resource, cfg config.Config, producers ...sdkmetric.Producer) *sdkmetric.MeterProvider {
+	var readerOpts []sdkmetric.PeriodicReaderOption
+	for _, producer := range producers {
+		readerOpts = append(readerOpts, sdkmetric.WithProducer(producer))
+	}
+	if cfg.MetricInterval > 0 {
+		readerOpts = append(readerOpts, sdkmetric.WithInterval(cfg.MetricInterval))
+	}
+	if cfg.MetricTimeout > 0 {
+		readerOpts = append(readerOpts, sdkmetric.WithTimeout(cfg.MetricTimeout))
+	}
+
+	return sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exp, readerOpts...)),
+		sdkmetric.WithResource(res),
+		sdkmetric.WithView(cfg.Views...),
+	)
+}
+
+// temporalitySelector returns the exporter temporality for a preference, see WithTemporality.
+func temporalitySelector(temporality string) sdkmetric.TemporalitySelector {
+	switch temporality {
+	case config.TemporalityDelta:
+		return func(kind sdkmetric.InstrumentKind) metricdata.Temporality {
+			switch kind {
+			case sdkmetric.InstrumentKindUpDownCounter, sdkmetric.InstrumentKindObservableUpDownCounter:
+				return metricdata.CumulativeTemporality
+			default:
+				return metricdata.DeltaTemporality
+			}
+		}
+	case config.TemporalityLowMemory:
+		return func(kind sdkmetric.InstrumentKind) metricdata.Temporality {
+			switch kind {
+			case sdkmetric.InstrumentKindCounter, sdkmetric.InstrumentKindHistogram:
+				return metricdata.DeltaTemporality
+			default:
+				return metricdata.CumulativeTemporality
+			}
+		}
+	default:
+		return sdkmetric.DefaultTemporalitySelector
+	}
+}
+
+// histogramTemporality returns the temporality of the runtime histograms, which the exporter
+// temporality selector does not convert. Like asynchronous counters they observe totals, so
+// only the delta preference turns them into deltas.
+func histogramTemporality(temporality string) metricdata.Temporality {
+	return temporalitySelector(temporality)(sdkmetric.InstrumentKindObservableCounter)
+}
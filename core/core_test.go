@@ -0,0 +1,381 @@
+package core_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/idnandre/gobsv/core"
+	"github.com/idnandre/gobsv/http/middleware/nethttp"
+	"github.com/idnandre/gobsv/internal/otlptest"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// exportGRPC builds an OTLP/gRPC pipeline, exports one span and one round of metrics and
+// shuts the pipeline down.
+func exportGRPC(t *testing.T, opts ...core.Option) {
+	t.Helper()
+	export(t, append([]core.Option{core.WithProtocol(core.ProtocolGRPC)}, opts...)...)
+}
+
+// exportHTTP is exportGRPC over OTLP/HTTP.
+func exportHTTP(t *testing.T, opts ...core.Option) {
+	t.Helper()
+	export(t, append([]core.Option{core.WithProtocol(core.ProtocolHTTPProtobuf)}, opts...)...)
+}
+
+func export(t *testing.T, opts ...core.Option) {
+	t.Helper()
+	ctx := context.Background()
+
+	opts = append([]core.Option{
+		core.WithServiceName("export-test"),
+		core.WithRuntimeMetrics(core.RuntimeMetricsMinimal),
+		core.WithGlobal(false),
+	}, opts...)
+	p, err := core.New(ctx, opts...)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	_, span := p.Tracer("test").Start(ctx, "export-span")
+	span.End()
+
+	if err := p.ForceFlush(ctx); err != nil {
+		t.Fatalf("ForceFlush() error = %v", err)
+	}
+	if err := p.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+}
+
+func checkReceived(t *testing.T, c *otlptest.Collector) {
+	t.Helper()
+	if names := c.SpanNames(); len(names) != 1 || names[0] != "export-span" {
+		t.Errorf("spans = %v, want [export-span]", names)
+	}
+	if len(c.Metrics()) == 0 {
+		t.Error("no metrics received")
+	}
+}
+
+func checkReceivedHTTP(t *testing.T, c *otlptest.HTTPCollector) {
+	t.Helper()
+	if names := c.SpanNames(); len(names) != 1 || names[0] != "export-span" {
+		t.Errorf("spans = %v, want [export-span]", names)
+	}
+	if len(c.Metrics()) == 0 {
+		t.Error("no metrics received")
+	}
+}
+
+func TestGRPCEndpoint(t *testing.T) {
+	for name, endpoint := range map[string]func(addr string) string{
+		"host and port": func(addr string) string { return addr },
+		"http URL":      func(addr string) string { return "http://" + addr },
+	} {
+		t.Run(name, func(t *testing.T) {
+			c := otlptest.NewCollector(t)
+			exportGRPC(t, core.WithEndpoint(endpoint(c.Addr)))
+			checkReceived(t, c)
+		})
+	}
+}
+
+func TestGRPCHeaders(t *testing.T) {
+	c := otlptest.NewCollector(t)
+	exportGRPC(t,
+		core.WithEndpoint(c.Addr),
+		core.WithHeaders(map[string]string{"x-api-key": "static", "x-tenant": "static"}),
+		core.WithHeadersFunc(func(context.Context) (map[string]string, error) {
+			return map[string]string{"authorization": "Bearer token", "x-tenant": "dynamic"}, nil
+		}),
+	)
+	checkReceived(t, c)
+
+	headers := c.Headers()
+	if len(headers) == 0 {
+		t.Fatal("no export requests received")
+	}
+	for _, md := range headers {
+		for key, want := range map[string]string{
+			"x-api-key":     "static",
+			"x-tenant":      "dynamic",
+			"authorization": "Bearer token",
+		} {
+			if got := md.Get(key); len(got) != 1 || got[0] != want {
+				t.Errorf("header %s = %v, want %q", key, got, want)
+			}
+		}
+	}
+}
+
+func TestGRPCCompression(t *testing.T) {
+	for compression, want := range map[core.Compression]string{
+		core.NoCompression:   "",
+		core.GzipCompression: "gzip",
+	} {
+		t.Run(string(compression), func(t *testing.T) {
+			c := otlptest.NewCollector(t)
+			exportGRPC(t, core.WithEndpoint(c.Addr), core.WithCompression(compression))
+			checkReceived(t, c)
+
+			received := c.Compression()
+			if len(received) == 0 {
+				t.Fatal("no export requests received")
+			}
+			for _, got := range received {
+				if got != want {
+					t.Errorf("compression = %q, want %q", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestGRPCTLS(t *testing.T) {
+	cert, certPEM := otlptest.Certificate(t)
+	serverTLS := grpc.Creds(credentials.NewTLS(&tls.Config{Certificates: []tls.Certificate{cert}}))
+
+	t.Run("config", func(t *testing.T) {
+		pool := x509.NewCertPool()
+		pool.AppendCertsFromPEM(certPEM)
+
+		c := otlptest.NewCollector(t, serverTLS)
+		exportGRPC(t, core.WithEndpoint(c.Addr), core.WithTLSConfig(&tls.Config{RootCAs: pool}))
+		checkReceived(t, c)
+	})
+
+	t.Run("CA file", func(t *testing.T) {
+		caFile := filepath.Join(t.TempDir(), "ca.pem")
+		if err := os.WriteFile(caFile, certPEM, 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		c := otlptest.NewCollector(t, serverTLS)
+		exportGRPC(t, core.WithEndpoint("https://"+c.Addr), core.WithCACertFile(caFile))
+		checkReceived(t, c)
+	})
+
+	t.Run("untrusted", func(t *testing.T) {
+		c := otlptest.NewCollector(t, serverTLS)
+		ctx := context.Background()
+		p, err := core.New(ctx,
+			core.WithProtocol(core.ProtocolGRPC),
+			core.WithEndpoint(c.Addr),
+			core.WithTLSConfig(&tls.Config{}),
+			core.WithRetry(core.RetryConfig{Enabled: false}),
+			core.WithGlobal(false),
+		)
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		defer p.Shutdown(ctx)
+
+		_, span := p.Tracer("test").Start(ctx, "grpc-span")
+		span.End()
+		if err := p.ForceFlush(ctx); err == nil {
+			t.Error("ForceFlush() succeeded against an untrusted certificate")
+		}
+		if len(c.Spans()) != 0 {
+			t.Error("spans received over an untrusted connection")
+		}
+	})
+}
+
+func TestHTTPTLS(t *testing.T) {
+	cert, certPEM := otlptest.Certificate(t)
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(certPEM)
+
+	// checkTLS checks that every export request was sent over TLS
+	checkTLS := func(t *testing.T, c *otlptest.HTTPCollector) {
+		t.Helper()
+		checkReceivedHTTP(t, c)
+		for _, req := range c.Requests() {
+			if req.TLS == nil {
+				t.Errorf("%s received over plain HTTP", req.Path)
+			}
+		}
+	}
+
+	t.Run("config", func(t *testing.T) {
+		c := otlptest.NewHTTPCollector(t, &tls.Config{Certificates: []tls.Certificate{cert}})
+		exportHTTP(t, core.WithEndpoint(c.Addr), core.WithTLSConfig(&tls.Config{RootCAs: pool}))
+		checkTLS(t, c)
+	})
+
+	t.Run("client certificate", func(t *testing.T) {
+		clientCert, clientPEM := otlptest.Certificate(t)
+		clientCAs := x509.NewCertPool()
+		clientCAs.AppendCertsFromPEM(clientPEM)
+		certFile, keyFile := otlptest.WriteKeyPair(t, clientCert)
+		caFile := filepath.Join(t.TempDir(), "ca.pem")
+		if err := os.WriteFile(caFile, certPEM, 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		c := otlptest.NewHTTPCollector(t, &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+			ClientCAs:    clientCAs,
+		})
+		exportHTTP(t, core.WithEndpoint(c.Addr), core.WithCACertFile(caFile), core.WithClientCertFiles(certFile, keyFile))
+		checkTLS(t, c)
+
+		for _, req := range c.Requests() {
+			if req.TLS == nil || len(req.TLS.PeerCertificates) == 0 || !bytes.Equal(req.TLS.PeerCertificates[0].Raw, clientCert.Certificate[0]) {
+				t.Errorf("%s not authenticated with the client certificate", req.Path)
+			}
+		}
+	})
+
+	// The certificate is not valid for the address the exporters connect to
+	serverCert, serverPEM := otlptest.Certificate(t, "collector.internal")
+	serverPool := x509.NewCertPool()
+	serverPool.AppendCertsFromPEM(serverPEM)
+
+	t.Run("server name", func(t *testing.T) {
+		c := otlptest.NewHTTPCollector(t, &tls.Config{Certificates: []tls.Certificate{serverCert}})
+		exportHTTP(t,
+			core.WithEndpoint(c.Addr),
+			core.WithTLSConfig(&tls.Config{RootCAs: serverPool}),
+			core.WithServerName("collector.internal"),
+		)
+		checkTLS(t, c)
+	})
+
+	t.Run("without server name", func(t *testing.T) {
+		c := otlptest.NewHTTPCollector(t, &tls.Config{Certificates: []tls.Certificate{serverCert}})
+		ctx := context.Background()
+		p, err := core.New(ctx,
+			core.WithProtocol(core.ProtocolHTTPProtobuf),
+			core.WithEndpoint(c.Addr),
+			core.WithTLSConfig(&tls.Config{RootCAs: serverPool}),
+			core.WithRetry(core.RetryConfig{Enabled: false}),
+			core.WithGlobal(false),
+		)
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		defer p.Shutdown(ctx)
+
+		_, span := p.Tracer("test").Start(ctx, "http-span")
+		span.End()
+		if err := p.ForceFlush(ctx); err == nil {
+			t.Error("ForceFlush() succeeded against a certificate for another host")
+		}
+		if len(c.Requests()) != 0 {
+			t.Error("requests received over an unverified connection")
+		}
+	})
+}
+
+func TestOptionsOverrideEnvironment(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://unreachable:4318/otlp")
+	t.Setenv("OTEL_EXPORTER_OTLP_COMPRESSION", "gzip")
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "x-api-key=env")
+
+	t.Run("http", func(t *testing.T) {
+		c := otlptest.NewHTTPCollector(t, nil)
+		exportHTTP(t,
+			core.WithEndpoint(c.Addr),
+			core.WithCompression(core.NoCompression),
+			core.WithHeaders(map[string]string{"x-api-key": "option"}),
+		)
+		checkReceivedHTTP(t, c)
+
+		for _, req := range c.Requests() {
+			if req.Path != "/v1/traces" && req.Path != "/v1/metrics" {
+				t.Errorf("path = %q, want /v1/traces or /v1/metrics", req.Path)
+			}
+			if got := req.Header.Get("Content-Encoding"); got != "" {
+				t.Errorf("Content-Encoding = %q, want none", got)
+			}
+			if got := req.Header.Values("X-Api-Key"); len(got) != 1 || got[0] != "option" {
+				t.Errorf("x-api-key = %v, want [option]", got)
+			}
+		}
+	})
+
+	t.Run("grpc", func(t *testing.T) {
+		c := otlptest.NewCollector(t)
+		exportGRPC(t,
+			core.WithEndpoint(c.Addr),
+			core.WithCompression(core.NoCompression),
+			core.WithHeaders(map[string]string{"x-api-key": "option"}),
+		)
+		checkReceived(t, c)
+
+		for _, got := range c.Compression() {
+			if got != "" {
+				t.Errorf("compression = %q, want none", got)
+			}
+		}
+		for _, md := range c.Headers() {
+			if got := md.Get("x-api-key"); len(got) != 1 || got[0] != "option" {
+				t.Errorf("x-api-key = %v, want [option]", got)
+			}
+		}
+	})
+}
+
+func TestViewsApplyToHTTPMetrics(t *testing.T) {
+	ctx := context.Background()
+	c := otlptest.NewCollector(t)
+	p, err := core.New(ctx,
+		core.WithProtocol(core.ProtocolGRPC),
+		core.WithEndpoint(c.Addr),
+		core.WithRuntimeMetrics(core.RuntimeMetricsNone),
+		core.WithGlobal(false),
+		core.WithViews(
+			core.HistogramBoundaries("http.server.request.duration", 0.1, 1),
+			core.ExponentialHistogram("http.server.response.body.size", 160, 20),
+			core.FilterAttributes("http.server.request.body.size", "http.request.method"),
+		),
+	)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer p.Shutdown(ctx)
+
+	handler := nethttp.TraceMiddleware(p)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if err := p.ForceFlush(ctx); err != nil {
+		t.Fatalf("ForceFlush() error = %v", err)
+	}
+
+	metrics := make(map[string]*metricpb.Metric)
+	for _, rm := range c.Metrics() {
+		for _, sm := range rm.GetScopeMetrics() {
+			for _, m := range sm.GetMetrics() {
+				metrics[m.GetName()] = m
+			}
+		}
+	}
+
+	duration := metrics["http.server.request.duration"].GetHistogram().GetDataPoints()
+	if len(duration) != 1 || !slices.Equal(duration[0].GetExplicitBounds(), []float64{0.1, 1}) {
+		t.Errorf("http.server.request.duration = %v, want the bounds [0.1 1]", duration)
+	}
+	if metrics["http.server.response.body.size"].GetExponentialHistogram() == nil {
+		t.Errorf("http.server.response.body.size = %v, want an exponential histogram", metrics["http.server.response.body.size"])
+	}
+	requestSize := metrics["http.server.request.body.size"].GetHistogram().GetDataPoints()
+	if len(requestSize) != 1 {
+		t.Errorf("http.server.request.body.size = %v, want one data point", requestSize)
+	}
+	for _, point := range requestSize {
+		if attrs := otlptest.Attributes(point.GetAttributes()); len(attrs) != 1 || attrs["http.request.method"] != http.MethodGet {
+			t.Errorf("http.server.request.body.size attributes = %v, want only http.request.method", attrs)
+		}
+	}
+}
@@ -0,0 +1,365 @@
+package core
+
+import (
+	"context"
+	"crypto/tls"
+	"time"
+
+	"github.com/idnandre/gobsv/internal/config"
+	"github.com/idnandre/gobsv/internal/httpconv"
+	"github.com/idnandre/gobsv/internal/metadata"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Option configures the telemetry pipeline created by New.
+//
+// Settings are resolved in the following order, each step overriding the previous one:
+// built-in defaults, the standard OTEL_* environment variables, and the Options passed to New.
+type Option func(*config.Config)
+
+// Protocol selects the OTLP transport used by the exporters.
+type Protocol string
+
+const (
+	// ProtocolHTTPProtobuf exports over OTLP/HTTP, by default to localhost:4318.
+	ProtocolHTTPProtobuf Protocol = config.ProtocolHTTPProtobuf
+	// ProtocolGRPC exports over OTLP/gRPC, by default to localhost:4317.
+	ProtocolGRPC Protocol = config.ProtocolGRPC
+)
+
+// WithProtocol sets the OTLP transport. Overrides OTEL_EXPORTER_OTLP_PROTOCOL.
+func WithProtocol(protocol Protocol) Option {
+	return func(c *config.Config) {
+		c.Protocol = string(protocol)
+	}
+}
+
+// WithEndpoint sets the OTLP receiver, either as "host:port" or as a URL like "https://collector:4318".
+// A URL with the https scheme enables TLS. Overrides OTEL_EXPORTER_OTLP_ENDPOINT.
+func WithEndpoint(endpoint string) Option {
+	return func(c *config.Config) {
+		c.Endpoint = endpoint
+	}
+}
+
+// Compression selects how export requests are compressed.
+type Compression string
+
+const (
+	NoCompression   Compression = "none"
+	GzipCompression Compression = config.CompressionGzip
+)
+
+// WithCompression sets the compression of export requests. Overrides OTEL_EXPORTER_OTLP_COMPRESSION.
+func WithCompression(compression Compression) Option {
+	return func(c *config.Config) {
+		c.Compression = string(compression)
+		if compression == NoCompression {
+			c.Compression = ""
+		}
+	}
+}
+
+// RetryConfig defines how failed exports are retried.
+type RetryConfig struct {
+	// Enabled turns retries on.
+	Enabled bool
+	// InitialInterval is the time to wait after the first failure.
+	InitialInterval time.Duration
+	// MaxInterval is the upper bound of the wait between attempts.
+	MaxInterval time.Duration
+	// MaxElapsedTime is the time after which an export is given up.
+	MaxElapsedTime time.Duration
+}
+
+// WithRetry sets the retry behaviour of the exporters. Without it the exporter defaults apply.
+func WithRetry(retry RetryConfig) Option {
+	return func(c *config.Config) {
+		r := config.Retry(retry)
+		c.Retry = &r
+	}
+}
+
+// WithServiceName sets the service.name resource attribute. Overrides OTEL_SERVICE_NAME.
+func WithServiceName(name string) Option {
+	return func(c *config.Config) {
+		c.ServiceName = name
+	}
+}
+
+// WithServiceVersion sets the service.version resource attribute.
+func WithServiceVersion(version string) Option {
+	return func(c *config.Config) {
+		c.ServiceVersion = version
+	}
+}
+
+// WithServiceNamespace sets the service.namespace resource attribute.
+func WithServiceNamespace(namespace string) Option {
+	return func(c *config.Config) {
+		c.ServiceNamespace = namespace
+	}
+}
+
+// WithEnvironment sets the deployment.environment resource attribute.
+func WithEnvironment(env string) Option {
+	return func(c *config.Config) {
+		c.Environment = env
+	}
+}
+
+// WithResourceAttributes sets extra resource attributes. Overrides OTEL_RESOURCE_ATTRIBUTES,
+// except for the service.name it sets, which only WithServiceName overrides.
+func WithResourceAttributes(attrs ...attribute.KeyValue) Option {
+	return func(c *config.Config) {
+		c.ResourceAttributes = attrs
+	}
+}
+
+// WithDetectors adds resource detectors whose attributes are added to the resource,
+// e.g. for the platform the service runs on.
+func WithDetectors(detectors ...resource.Detector) Option {
+	return func(c *config.Config) {
+		c.Detectors = append(c.Detectors, detectors...)
+	}
+}
+
+// WithHeaders sets headers sent with every export request, e.g. an API key.
+// Overrides OTEL_EXPORTER_OTLP_HEADERS.
+func WithHeaders(headers map[string]string) Option {
+	return func(c *config.Config) {
+		c.Headers = headers
+	}
+}
+
+// HeadersFunc returns headers for the next export request.
+type HeadersFunc func(ctx context.Context) (map[string]string, error)
+
+// WithHeadersFunc sets a function called before every trace and metric export whose headers
+// are merged over the static ones. Use it for credentials that rotate, such as bearer tokens.
+// An error returned by fn fails that export.
+func WithHeadersFunc(fn HeadersFunc) Option {
+	return func(c *config.Config) {
+		c.HeadersFunc = fn
+	}
+}
+
+// WithTLSConfig enables HTTPS towards the receiver using the given TLS configuration.
+// Without any TLS option the exporters connect over plain HTTP.
+func WithTLSConfig(tlsConfig *tls.Config) Option {
+	return func(c *config.Config) {
+		c.TLSConfig = tlsConfig
+	}
+}
+
+// WithCACertFile enables HTTPS and verifies the receiver against the PEM encoded CA bundle at path.
+// Overrides OTEL_EXPORTER_OTLP_CERTIFICATE.
+func WithCACertFile(path string) Option {
+	return func(c *config.Config) {
+		c.CAFile = path
+	}
+}
+
+// WithClientCertFiles enables mutual TLS with the PEM encoded client certificate and key.
+// Overrides OTEL_EXPORTER_OTLP_CLIENT_CERTIFICATE and OTEL_EXPORTER_OTLP_CLIENT_KEY.
+func WithClientCertFiles(certFile, keyFile string) Option {
+	return func(c *config.Config) {
+		c.ClientCertFile = certFile
+		c.ClientKeyFile = keyFile
+	}
+}
+
+// WithServerName enables HTTPS and overrides the host name used to verify the receiver certificate.
+func WithServerName(name string) Option {
+	return func(c *config.Config) {
+		c.ServerName = name
+	}
+}
+
+// WithSampler sets the sampler used by the trace provider, e.g. RateLimitingSampler or RuleSampler.
+// Overrides OTEL_TRACES_SAMPLER, which besides the samplers of the specification accepts
+// "ratelimiting" and "parentbased_ratelimiting" with OTEL_TRACES_SAMPLER_ARG traces per second.
+func WithSampler(sampler sdktrace.Sampler) Option {
+	return func(c *config.Config) {
+		c.Sampler = sampler
+	}
+}
+
+// WithPropagators sets the propagator used by the middlewares to extract and inject trace
+// context, and registered as the global text map propagator. Overrides OTEL_PROPAGATORS.
+func WithPropagators(propagator propagation.TextMapPropagator) Option {
+	return func(c *config.Config) {
+		c.Propagator = propagator
+	}
+}
+
+// WithPropagatorNames sets the propagators by the names used in OTEL_PROPAGATORS:
+// "tracecontext", "baggage", "b3", "b3multi", "jaeger", "xray" and "none". They are combined
+// in the given order. It defaults to "tracecontext" and "baggage". Overrides OTEL_PROPAGATORS.
+func WithPropagatorNames(names ...string) Option {
+	return func(c *config.Config) {
+		c.Propagator = nil
+		c.PropagatorNames = names
+	}
+}
+
+// WithMetricInterval sets how often metrics are exported. Overrides OTEL_METRIC_EXPORT_INTERVAL.
+func WithMetricInterval(interval time.Duration) Option {
+	return func(c *config.Config) {
+		c.MetricInterval = interval
+	}
+}
+
+// WithMetricTimeout sets how long an export of metrics may take, including the collection of
+// asynchronous instruments. It defaults to 30 seconds. Overrides OTEL_METRIC_EXPORT_TIMEOUT.
+func WithMetricTimeout(timeout time.Duration) Option {
+	return func(c *config.Config) {
+		c.MetricTimeout = timeout
+	}
+}
+
+// Temporality selects whether exported metrics are cumulative or delta.
+type Temporality string
+
+const (
+	// TemporalityCumulative exports every metric as the total since the process started.
+	TemporalityCumulative Temporality = config.TemporalityCumulative
+	// TemporalityDelta exports counters and histograms as the change since the last export,
+	// up-down counters stay cumulative.
+	TemporalityDelta Temporality = config.TemporalityDelta
+	// TemporalityLowMemory exports synchronous counters and histograms as delta and the
+	// asynchronous counters, such as the runtime metrics, as cumulative.
+	TemporalityLowMemory Temporality = config.TemporalityLowMemory
+)
+
+// WithTemporality sets the temporality preference of metrics, as defined for
+// OTEL_EXPORTER_OTLP_METRICS_TEMPORALITY_PREFERENCE. It defaults to TemporalityCumulative,
+// use TemporalityDelta for backends that only accept delta metrics. Overrides the variable.
+func WithTemporality(temporality Temporality) Option {
+	return func(c *config.Config) {
+		c.Temporality = string(temporality)
+	}
+}
+
+// WithViews adds views customizing the metrics of matching instruments, see HistogramBoundaries,
+// ExponentialHistogram and FilterAttributes. Views apply to the runtime, process and HTTP
+// metrics, except the runtime histograms which keep the buckets of runtime/metrics.
+func WithViews(views ...sdkmetric.View) Option {
+	return func(c *config.Config) {
+		c.Views = append(c.Views, views...)
+	}
+}
+
+// RuntimeMetricsPreset selects a set of Go runtime metrics to export.
+type RuntimeMetricsPreset string
+
+const (
+	// RuntimeMetricsNone exports no runtime metrics, e.g. for short lived Lambda functions.
+	RuntimeMetricsNone RuntimeMetricsPreset = metadata.PresetNone
+	// RuntimeMetricsMinimal exports heap size, goroutines, GC cycles and GC pauses.
+	RuntimeMetricsMinimal RuntimeMetricsPreset = metadata.PresetMinimal
+	// RuntimeMetricsDefault exports all runtime metrics except the per-setting /godebug/ counters.
+	RuntimeMetricsDefault RuntimeMetricsPreset = metadata.PresetDefault
+	// RuntimeMetricsAll exports every metric of runtime/metrics.
+	RuntimeMetricsAll RuntimeMetricsPreset = metadata.PresetAll
+)
+
+// WithRuntimeMetrics sets the preset of Go runtime metrics to export. It defaults to RuntimeMetricsDefault.
+func WithRuntimeMetrics(preset RuntimeMetricsPreset) Option {
+	return func(c *config.Config) {
+		c.RuntimeMetrics = string(preset)
+	}
+}
+
+// WithRuntimeMetricsInclude exports the runtime metrics matching any of the patterns in
+// addition to the preset. A pattern is either a runtime/metrics name such as
+// "/sched/goroutines:goroutines" or a glob where "*" matches any characters, including "/".
+func WithRuntimeMetricsInclude(patterns ...string) Option {
+	return func(c *config.Config) {
+		c.RuntimeMetricsInclude = append(c.RuntimeMetricsInclude, patterns...)
+	}
+}
+
+// WithRuntimeMetricsExclude drops the runtime metrics matching any of the patterns, even when
+// part of the preset or included. Patterns are written as for WithRuntimeMetricsInclude.
+func WithRuntimeMetricsExclude(patterns ...string) Option {
+	return func(c *config.Config) {
+		c.RuntimeMetricsExclude = append(c.RuntimeMetricsExclude, patterns...)
+	}
+}
+
+// RuntimeMetricsNaming selects how Go runtime metrics are named.
+type RuntimeMetricsNaming string
+
+const (
+	// RuntimeMetricsNamingSemconv names runtime metrics after the OpenTelemetry semantic
+	// conventions, e.g. go.memory.used or go.goroutine.count, with UCUM units. Metrics without
+	// a convention are named after their runtime/metrics name, e.g. go.gc.cycles.total.
+	RuntimeMetricsNamingSemconv RuntimeMetricsNaming = metadata.NamingSemconv
+	// RuntimeMetricsNamingLegacy keeps the names of earlier releases, e.g. gc_heap_allocs_bytes,
+	// with the Namespace, Subsystem and Units attributes.
+	RuntimeMetricsNamingLegacy RuntimeMetricsNaming = metadata.NamingLegacy
+)
+
+// WithRuntimeMetricsNaming sets how runtime metrics are named. It defaults to
+// RuntimeMetricsNamingSemconv; use RuntimeMetricsNamingLegacy to keep existing dashboards working.
+func WithRuntimeMetricsNaming(naming RuntimeMetricsNaming) Option {
+	return func(c *config.Config) {
+		c.RuntimeMetricsNaming = string(naming)
+	}
+}
+
+// WithProcessMetrics sets whether the process CPU time, memory, threads and open file
+// descriptors, and the CPU throttling and memory usage and limits of the container, are exported
+// as process.* and container.* metrics. They are read from /proc and the cgroup v1 or v2
+// file system, so only Linux reports them; the cgroup of the process is found through
+// /proc/self/cgroup. It defaults to false.
+func WithProcessMetrics(enabled bool) Option {
+	return func(c *config.Config) {
+		c.ProcessMetrics = enabled
+	}
+}
+
+// HTTPSemconv selects the span attributes set by the HTTP and Lambda middlewares.
+type HTTPSemconv string
+
+const (
+	// HTTPSemconvOld sets the attributes of earlier releases, such as http.method, http.target,
+	// http.useragent and http.status_code.
+	HTTPSemconvOld HTTPSemconv = httpconv.SemconvOld
+	// HTTPSemconvNew sets the stable HTTP semantic conventions, such as http.request.method,
+	// url.path, user_agent.original and http.response.status_code.
+	HTTPSemconvNew HTTPSemconv = httpconv.SemconvNew
+	// HTTPSemconvBoth sets both, to migrate dashboards and alerts.
+	HTTPSemconvBoth HTTPSemconv = httpconv.SemconvBoth
+)
+
+// WithHTTPSemconv sets the span attributes of the middlewares. Following the opt-in of the
+// OpenTelemetry instrumentations, it defaults to HTTPSemconvOld, or to HTTPSemconvNew when
+// OTEL_SEMCONV_STABILITY_OPT_IN contains "http" and HTTPSemconvBoth when it contains
+// "http/dup". Overrides the variable.
+func WithHTTPSemconv(mode HTTPSemconv) Option {
+	return func(c *config.Config) {
+		c.HTTPSemconv = string(mode)
+	}
+}
+
+// WithErrorStackTraces sets whether the exception events the middlewares record for handler
+// errors and panics carry the stack trace. It defaults to false.
+func WithErrorStackTraces(enabled bool) Option {
+	return func(c *config.Config) {
+		c.ErrorStackTraces = enabled
+	}
+}
+
+// WithGlobal sets whether New registers the providers and propagator as the OpenTelemetry
+// globals. It defaults to true; disable it to run several independent pipelines in one process.
+func WithGlobal(enabled bool) Option {
+	return func(c *config.Config) {
+		c.Global = enabled
+	}
+}
@@ -0,0 +1,157 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"github.com/idnandre/gobsv/internal/procfs"
+	"go.opentelemetry.io/otel/attribute"
+	otelmetric "go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+var (
+	cpuModeUser   = otelmetric.WithAttributeSet(attribute.NewSet(attribute.Key("cpu.mode").String("user")))
+	cpuModeSystem = otelmetric.WithAttributeSet(attribute.NewSet(attribute.Key("cpu.mode").String("system")))
+)
+
+// addProcessMetrics registers the process.* and container.* metrics read from the proc and
+// cgroup file systems. Metrics whose source cannot be read at startup, e.g. outside Linux,
+// without a cgroup file system or in a root cgroup without memory.current, are not registered.
+func addProcessMetrics(provider *sdkmetric.MeterProvider, fs procfs.FS) error {
+	meter := provider.Meter(instrumentationName)
+
+	var (
+		instruments []otelmetric.Observable
+		observers   []func(otelmetric.Observer, procfs.Process, procfs.Cgroup)
+		err         error
+	)
+	// register appends an instrument unless an earlier registration failed
+	register := func(instrument otelmetric.Observable, ierr error, observer func(otelmetric.Observer, procfs.Process, procfs.Cgroup)) {
+		if err = errors.Join(err, ierr); ierr == nil {
+			instruments = append(instruments, instrument)
+			observers = append(observers, observer)
+		}
+	}
+
+	_, processErr := fs.Process()
+	if processErr == nil {
+		cpuTime, ierr := meter.Float64ObservableCounter("process.cpu.time",
+			otelmetric.WithDescription("Total CPU seconds broken down by different CPU modes."), otelmetric.WithUnit("s"))
+		register(cpuTime, ierr, func(o otelmetric.Observer, p procfs.Process, _ procfs.Cgroup) {
+			o.ObserveFloat64(cpuTime, p.UserTime, cpuModeUser)
+			o.ObserveFloat64(cpuTime, p.SystemTime, cpuModeSystem)
+		})
+
+		rss, ierr := meter.Int64ObservableUpDownCounter("process.memory.usage",
+			otelmetric.WithDescription("The amount of physical memory in use."), otelmetric.WithUnit("By"))
+		register(rss, ierr, func(o otelmetric.Observer, p procfs.Process, _ procfs.Cgroup) {
+			o.ObserveInt64(rss, p.RSS)
+		})
+
+		virtual, ierr := meter.Int64ObservableUpDownCounter("process.memory.virtual",
+			otelmetric.WithDescription("The amount of committed virtual memory."), otelmetric.WithUnit("By"))
+		register(virtual, ierr, func(o otelmetric.Observer, p procfs.Process, _ procfs.Cgroup) {
+			o.ObserveInt64(virtual, p.VirtualMemory)
+		})
+
+		threads, ierr := meter.Int64ObservableUpDownCounter("process.thread.count",
+			otelmetric.WithDescription("Process threads count."), otelmetric.WithUnit("{thread}"))
+		register(threads, ierr, func(o otelmetric.Observer, p procfs.Process, _ procfs.Cgroup) {
+			o.ObserveInt64(threads, p.Threads)
+		})
+
+		fds, ierr := meter.Int64ObservableUpDownCounter("process.open_file_descriptor.count",
+			otelmetric.WithDescription("Number of file descriptors in use by the process."), otelmetric.WithUnit("{count}"))
+		register(fds, ierr, func(o otelmetric.Observer, p procfs.Process, _ procfs.Cgroup) {
+			o.ObserveInt64(fds, p.OpenFDs)
+		})
+	}
+
+	cgroup, cgroupErr := fs.Cgroup()
+	if cgroupErr == nil {
+		if cgroup.HasCPUTime {
+			cpuTime, ierr := meter.Float64ObservableCounter("container.cpu.time",
+				otelmetric.WithDescription("Total CPU time consumed by the container."), otelmetric.WithUnit("s"))
+			register(cpuTime, ierr, func(o otelmetric.Observer, _ procfs.Process, c procfs.Cgroup) {
+				if c.HasCPUTime {
+					o.ObserveFloat64(cpuTime, c.CPUTime)
+				}
+			})
+		}
+
+		if cgroup.HasThrottling {
+			throttledPeriods, ierr := meter.Int64ObservableCounter("container.cpu.throttled.periods",
+				otelmetric.WithDescription("Number of CPU enforcement periods the container was throttled in."), otelmetric.WithUnit("{period}"))
+			register(throttledPeriods, ierr, func(o otelmetric.Observer, _ procfs.Process, c procfs.Cgroup) {
+				if c.HasThrottling {
+					o.ObserveInt64(throttledPeriods, c.ThrottledPeriods)
+				}
+			})
+
+			throttledTime, ierr := meter.Float64ObservableCounter("container.cpu.throttled.time",
+				otelmetric.WithDescription("Total time the container was throttled for."), otelmetric.WithUnit("s"))
+			register(throttledTime, ierr, func(o otelmetric.Observer, _ procfs.Process, c procfs.Cgroup) {
+				if c.HasThrottling {
+					o.ObserveFloat64(throttledTime, c.ThrottledTime)
+				}
+			})
+		}
+
+		cpuLimit, ierr := meter.Float64ObservableUpDownCounter("container.cpu.limit",
+			otelmetric.WithDescription("CPU quota of the container, only reported when limited."), otelmetric.WithUnit("{cpu}"))
+		register(cpuLimit, ierr, func(o otelmetric.Observer, _ procfs.Process, c procfs.Cgroup) {
+			if c.CPULimit > 0 {
+				o.ObserveFloat64(cpuLimit, c.CPULimit)
+			}
+		})
+
+		if cgroup.HasMemoryUsage {
+			memoryUsage, ierr := meter.Int64ObservableUpDownCounter("container.memory.usage",
+				otelmetric.WithDescription("Memory usage of the container."), otelmetric.WithUnit("By"))
+			register(memoryUsage, ierr, func(o otelmetric.Observer, _ procfs.Process, c procfs.Cgroup) {
+				if c.HasMemoryUsage {
+					o.ObserveInt64(memoryUsage, c.MemoryUsage)
+				}
+			})
+		}
+
+		memoryLimit, ierr := meter.Int64ObservableUpDownCounter("container.memory.limit",
+			otelmetric.WithDescription("Memory limit of the container, only reported when limited."), otelmetric.WithUnit("By"))
+		register(memoryLimit, ierr, func(o otelmetric.Observer, _ procfs.Process, c procfs.Cgroup) {
+			if c.MemoryLimit > 0 {
+				o.ObserveInt64(memoryLimit, c.MemoryLimit)
+			}
+		})
+	}
+	if err != nil || len(instruments) == 0 {
+		return err
+	}
+
+	// Both file systems are read once per collection
+	_, err = meter.RegisterCallback(func(_ context.Context, o otelmetric.Observer) error {
+		var process procfs.Process
+		var cgroup procfs.Cgroup
+		var err error
+		if processErr == nil {
+			process, err = fs.Process()
+		}
+		if cgroupErr == nil {
+			var cerr error
+			cgroup, cerr = fs.Cgroup()
+			if !errors.Is(cerr, os.ErrNotExist) {
+				err = errors.Join(err, cerr)
+			}
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, observe := range observers {
+			observe(o, process, cgroup)
+		}
+		return nil
+	}, instruments...)
+	return err
+}
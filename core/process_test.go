@@ -0,0 +1,61 @@
+package core
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/idnandre/gobsv/internal/procfs"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestProcessMetrics(t *testing.T) {
+	processMetrics := []string{
+		"process.cpu.time",
+		"process.memory.usage",
+		"process.memory.virtual",
+		"process.open_file_descriptor.count",
+		"process.thread.count",
+	}
+	for _, tt := range []struct {
+		root string
+		want []string
+	}{
+		{"../internal/procfs/testdata/v2", append([]string{
+			"container.cpu.limit",
+			"container.cpu.throttled.periods",
+			"container.cpu.throttled.time",
+			"container.cpu.time",
+			"container.memory.limit",
+			"container.memory.usage",
+		}, processMetrics...)},
+		// Neither throttling nor memory usage nor limits are reported as 0
+		{"../internal/procfs/testdata/v2-root", []string{"container.cpu.time"}},
+		{t.TempDir(), nil},
+	} {
+		t.Run(tt.root, func(t *testing.T) {
+			reader := sdkmetric.NewManualReader()
+			provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+			if err := addProcessMetrics(provider, procfs.NewFS(tt.root)); err != nil {
+				t.Fatalf("addProcessMetrics() error = %v", err)
+			}
+
+			var rm metricdata.ResourceMetrics
+			if err := reader.Collect(context.Background(), &rm); err != nil {
+				t.Fatalf("Collect() error = %v", err)
+			}
+			var names []string
+			for _, sm := range rm.ScopeMetrics {
+				for _, m := range sm.Metrics {
+					names = append(names, m.Name)
+				}
+			}
+			slices.Sort(names)
+			slices.Sort(tt.want)
+			if !slices.Equal(names, tt.want) {
+				t.Errorf("metrics = %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/idnandre/gobsv/internal/config"
+	"go.opentelemetry.io/otel"
+	otelmetric "go.opentelemetry.io/otel/metric"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
+)
+
+// Provider owns the trace and metric pipelines created by New.
+//
+// A nil Provider is valid and records nothing, so a service can keep running without
+// telemetry when New fails.
+type Provider struct {
+	traceProvider *sdktrace.TracerProvider
+	meterProvider *sdkmetric.MeterProvider
+	propagator    propagation.TextMapPropagator
+	config        config.Config
+
+	shutdownOnce sync.Once
+	shutdownErr  error
+}
+
+// SetGlobal registers the providers and propagator as the OpenTelemetry globals.
+func (p *Provider) SetGlobal() {
+	if p == nil {
+		return
+	}
+	otel.SetTracerProvider(p.traceProvider)
+	otel.SetMeterProvider(p.meterProvider)
+	otel.SetTextMapPropagator(p.propagator)
+}
+
+// TracerProvider returns the underlying trace provider, e.g. to pass it to other instrumentation.
+func (p *Provider) TracerProvider() trace.TracerProvider {
+	if p == nil {
+		return tracenoop.NewTracerProvider()
+	}
+	return p.traceProvider
+}
+
+// MeterProvider returns the underlying meter provider, e.g. to pass it to other instrumentation.
+func (p *Provider) MeterProvider() otelmetric.MeterProvider {
+	if p == nil {
+		return metricnoop.NewMeterProvider()
+	}
+	return p.meterProvider
+}
+
+// HTTPSemconv returns the span attributes the middlewares set, see WithHTTPSemconv.
+func (p *Provider) HTTPSemconv() HTTPSemconv {
+	if p == nil {
+		return HTTPSemconvOld
+	}
+	return HTTPSemconv(p.config.HTTPSemconv)
+}
+
+// ErrorStackTraces reports whether the middlewares record stack traces, see WithErrorStackTraces.
+func (p *Provider) ErrorStackTraces() bool {
+	return p != nil && p.config.ErrorStackTraces
+}
+
+// Tracer returns a tracer for the named instrumentation scope.
+func (p *Provider) Tracer(name string, opts ...trace.TracerOption) trace.Tracer {
+	return p.TracerProvider().Tracer(name, opts...)
+}
+
+// Meter returns a meter for the named instrumentation scope.
+func (p *Provider) Meter(name string, opts ...otelmetric.MeterOption) otelmetric.Meter {
+	return p.MeterProvider().Meter(name, opts...)
+}
+
+// Propagator returns the propagator used to extract and inject trace context.
+func (p *Provider) Propagator() propagation.TextMapPropagator {
+	if p == nil {
+		return propagation.NewCompositeTextMapPropagator()
+	}
+	return p.propagator
+}
+
+// ForceFlush exports all spans and metrics that have not yet been exported.
+func (p *Provider) ForceFlush(ctx context.Context) error {
+	if p == nil {
+		return nil
+	}
+	return errors.Join(
+		p.traceProvider.ForceFlush(ctx),
+		p.meterProvider.ForceFlush(ctx),
+	)
+}
+
+// Shutdown flushes and stops the trace and meter providers in parallel and waits for both
+// to finish or for ctx to be done. The errors of both providers are joined.
+// Only the first call does the work, later calls return its result. Calling Shutdown on a
+// nil Provider is a no-op.
+func (p *Provider) Shutdown(ctx context.Context) error {
+	if p == nil {
+		return nil
+	}
+
+	p.shutdownOnce.Do(func() {
+		errs := make(chan error, 2)
+		go func() {
+			errs <- p.traceProvider.Shutdown(ctx)
+		}()
+		go func() {
+			errs <- p.meterProvider.Shutdown(ctx)
+		}()
+
+		for range 2 {
+			select {
+			case err := <-errs:
+				p.shutdownErr = errors.Join(p.shutdownErr, err)
+			case <-ctx.Done():
+				p.shutdownErr = errors.Join(p.shutdownErr, ctx.Err())
+				return
+			}
+		}
+	})
+	return p.shutdownErr
+}
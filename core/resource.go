@@ -0,0 +1,64 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/idnandre/gobsv/internal/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// newResource builds the resource shared by the trace and meter providers.
+// Attributes are resolved in the following order, each step overriding the previous one:
+// telemetry SDK, detected host, OS, process and container attributes, platform detectors,
+// the configured resource attributes and finally the service and environment settings.
+// The OTEL_* resource variables are not read here but by config.Load, so that options override them.
+func newResource(ctx context.Context, cfg config.Config) (*resource.Resource, error) {
+	detected, err := resource.New(
+		ctx,
+		resource.WithTelemetrySDK(),
+		resource.WithHost(),
+		resource.WithOS(),
+		resource.WithProcess(),
+		resource.WithContainer(),
+		resource.WithDetectors(cfg.Detectors...),
+	)
+	switch {
+	case errors.Is(err, resource.ErrSchemaURLConflict):
+		return nil, fmt.Errorf("failed to detect resource: %w", err)
+	case errors.Is(err, resource.ErrPartialResource):
+		// A single detector failing, e.g. to look up the process owner, must not
+		// disable telemetry. Keep what was detected and report the rest.
+		otel.Handle(err)
+	case err != nil:
+		return nil, fmt.Errorf("failed to detect resource: %w", err)
+	}
+
+	// Like the SDK default, fall back to the executable name when no service name is configured.
+	attrs := []attribute.KeyValue{semconv.ServiceName("unknown_service:" + filepath.Base(os.Args[0]))}
+	attrs = append(attrs, cfg.ResourceAttributes...)
+	if cfg.ServiceName != "" {
+		attrs = append(attrs, semconv.ServiceName(cfg.ServiceName))
+	}
+	if cfg.ServiceVersion != "" {
+		attrs = append(attrs, semconv.ServiceVersion(cfg.ServiceVersion))
+	}
+	if cfg.ServiceNamespace != "" {
+		attrs = append(attrs, semconv.ServiceNamespace(cfg.ServiceNamespace))
+	}
+	if cfg.Environment != "" {
+		attrs = append(attrs, semconv.DeploymentEnvironment(cfg.Environment))
+	}
+
+	res, err := resource.Merge(detected, resource.NewWithAttributes(semconv.SchemaURL, attrs...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge resource: %w", err)
+	}
+	return res, nil
+}
@@ -0,0 +1,79 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/idnandre/gobsv/internal/config"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+func loadResource(t *testing.T, opts ...Option) *resource.Resource {
+	t.Helper()
+	cfg, err := config.Load(opts)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	res, err := newResource(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("newResource() error = %v", err)
+	}
+	return res
+}
+
+func TestResourceOptionsOverrideEnvironment(t *testing.T) {
+	t.Setenv("OTEL_SERVICE_NAME", "")
+	t.Setenv("OTEL_RESOURCE_ATTRIBUTES", "team=env,region=env,service.version=1.0.0")
+
+	res := loadResource(t, WithResourceAttributes(attribute.String("team", "option")))
+	set := res.Set()
+
+	if v, _ := set.Value("team"); v.AsString() != "option" {
+		t.Errorf("team = %q, want option", v.AsString())
+	}
+	for _, key := range []attribute.Key{"region", "service.version"} {
+		if v, ok := set.Value(key); ok {
+			t.Errorf("%s = %q leaked from OTEL_RESOURCE_ATTRIBUTES", key, v.AsString())
+		}
+	}
+	if v, _ := set.Value("telemetry.sdk.name"); v.AsString() != "opentelemetry" {
+		t.Errorf("telemetry.sdk.name = %q, want opentelemetry", v.AsString())
+	}
+}
+
+func TestResourceEnvironment(t *testing.T) {
+	t.Setenv("OTEL_SERVICE_NAME", "")
+	t.Setenv("OTEL_RESOURCE_ATTRIBUTES", "team=env,service.name=from-attributes,service.version=1.0.0")
+
+	set := loadResource(t).Set()
+	for key, want := range map[attribute.Key]string{
+		"team":            "env",
+		"service.name":    "from-attributes",
+		"service.version": "1.0.0",
+	} {
+		if v, _ := set.Value(key); v.AsString() != want {
+			t.Errorf("%s = %q, want %q", key, v.AsString(), want)
+		}
+	}
+
+	t.Setenv("OTEL_SERVICE_NAME", "from-variable")
+	set = loadResource(t, WithServiceVersion("2.0.0")).Set()
+	if v, _ := set.Value("service.name"); v.AsString() != "from-variable" {
+		t.Errorf("service.name = %q, want from-variable", v.AsString())
+	}
+	if v, _ := set.Value("service.version"); v.AsString() != "2.0.0" {
+		t.Errorf("service.version = %q, want 2.0.0", v.AsString())
+	}
+}
+
+func TestResourceDefaultServiceName(t *testing.T) {
+	t.Setenv("OTEL_SERVICE_NAME", "")
+	t.Setenv("OTEL_RESOURCE_ATTRIBUTES", "")
+
+	set := loadResource(t).Set()
+	if v, _ := set.Value("service.name"); !strings.HasPrefix(v.AsString(), "unknown_service:") {
+		t.Errorf("service.name = %q, want unknown_service:<executable>", v.AsString())
+	}
+}
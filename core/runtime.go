@@ -0,0 +1,393 @@
+package core
+
+import (
+	"context"
+	"runtime/metrics"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/idnandre/gobsv/internal/metadata"
+	"go.opentelemetry.io/otel/attribute"
+	otelmetric "go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// instrumentationName is the meter scope of the runtime metrics named after the semantic conventions.
+// The legacy names keep the service name as scope.
+const instrumentationName = "github.com/idnandre/gobsv/core"
+
+// runtimeScope returns the meter scope of the runtime metrics for a naming scheme.
+func runtimeScope(serviceName, naming string) string {
+	if naming == metadata.NamingLegacy {
+		return serviceName
+	}
+	return instrumentationName
+}
+
+// addMetricsToOTEL registers the runtime metrics other than histograms, whose samples are
+// added to reader and read once per collection by a single callback.
+func addMetricsToOTEL(provider *sdkmetric.MeterProvider, serviceName, naming string, reader *runtimeReader, metricsMeta []metrics.Description) error {
+	meter := provider.Meter(runtimeScope(serviceName, naming))
+
+	var (
+		instruments []otelmetric.Observable
+		observers   []func(otelmetric.Observer)
+	)
+
+	// Register metrics, their values are read together in a single callback
+	for i := range metricsMeta {
+		// Get metric options
+		meta := metricsMeta[i]
+		if meta.Kind == metrics.KindFloat64Histogram {
+			// Exported by runtimeHistograms
+			continue
+		}
+
+		if naming != metadata.NamingLegacy {
+			if semconvMetric, ok := metadata.GetSemconvMetric(meta.Name); ok {
+				instrument, observer, err := newSemconvInstrument(meter, reader, semconvMetric)
+				if err != nil {
+					return err
+				}
+				instruments = append(instruments, instrument)
+				observers = append(observers, observer)
+				continue
+			}
+		}
+
+		name, option := normalizeOtelName(meta.Name), getMetricsOptions(meta)
+		if naming != metadata.NamingLegacy {
+			name = metadata.GetSemconvName(meta)
+			option = otelmetric.WithAttributeSet(*attribute.EmptySet())
+		}
+		instrument := metadata.GetInstrument(meta)
+		index := reader.add(meta.Name)
+
+		// Register metrics per type of metric, uint64 values as Int64 instruments
+		if instrument.Int {
+			observable, err := newInt64Observable(meter, name, instrument.Kind, meta.Description, instrument.Unit)
+			if err != nil {
+				return err
+			}
+			instruments = append(instruments, observable)
+			observers = append(observers, func(o otelmetric.Observer) {
+				o.ObserveInt64(observable, reader.uint64(index), option)
+			})
+		} else {
+			observable, err := newFloat64Observable(meter, name, instrument.Kind, meta.Description, instrument.Unit)
+			if err != nil {
+				return err
+			}
+			instruments = append(instruments, observable)
+			observers = append(observers, func(o otelmetric.Observer) {
+				o.ObserveFloat64(observable, metadata.GetSampleFloat(reader.samples[index]), option)
+			})
+		}
+	}
+	if len(instruments) == 0 {
+		return nil
+	}
+
+	_, err := meter.RegisterCallback(func(_ context.Context, o otelmetric.Observer) error {
+		reader.mu.Lock()
+		defer reader.mu.Unlock()
+
+		reader.read()
+		for _, observe := range observers {
+			observe(o)
+		}
+		return nil
+	}, instruments...)
+	return err
+}
+
+// newSemconvInstrument registers the instrument of a semantic convention metric and returns the
+// function observing its data points from the samples of reader.
+func newSemconvInstrument(meter otelmetric.Meter, reader *runtimeReader, metric metadata.SemconvMetric) (otelmetric.Int64Observable, func(otelmetric.Observer), error) {
+	instrument, err := newInt64Observable(meter, metric.Name, metric.Kind, metric.Description, metric.Unit)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	type point struct {
+		option   otelmetric.ObserveOption
+		add, sub []int
+		unset    int64
+	}
+	points := make([]point, 0, len(metric.Points))
+	for _, p := range metric.Points {
+		pt := point{option: otelmetric.WithAttributeSet(p.Attributes), unset: p.Unset}
+		for _, name := range p.Add {
+			pt.add = append(pt.add, reader.add(name))
+		}
+		for _, name := range p.Sub {
+			pt.sub = append(pt.sub, reader.add(name))
+		}
+		points = append(points, pt)
+	}
+
+	return instrument, func(o otelmetric.Observer) {
+		for _, pt := range points {
+			var value int64
+			for _, i := range pt.add {
+				value += reader.uint64(i)
+			}
+			for _, i := range pt.sub {
+				value -= reader.uint64(i)
+			}
+			if pt.unset != 0 && value == pt.unset {
+				continue
+			}
+			o.ObserveInt64(instrument, value, pt.option)
+		}
+	}, nil
+}
+
+// newInt64Observable registers the asynchronous Int64 instrument of a kind.
+func newInt64Observable(meter otelmetric.Meter, name string, kind metadata.InstrumentKind, description, unit string) (otelmetric.Int64Observable, error) {
+	switch kind {
+	case metadata.KindCounter:
+		return meter.Int64ObservableCounter(name, otelmetric.WithDescription(description), otelmetric.WithUnit(unit))
+	case metadata.KindUpDownCounter:
+		return meter.Int64ObservableUpDownCounter(name, otelmetric.WithDescription(description), otelmetric.WithUnit(unit))
+	default:
+		return meter.Int64ObservableGauge(name, otelmetric.WithDescription(description), otelmetric.WithUnit(unit))
+	}
+}
+
+// newFloat64Observable registers the asynchronous Float64 instrument of a kind.
+func newFloat64Observable(meter otelmetric.Meter, name string, kind metadata.InstrumentKind, description, unit string) (otelmetric.Float64Observable, error) {
+	switch kind {
+	case metadata.KindCounter:
+		return meter.Float64ObservableCounter(name, otelmetric.WithDescription(description), otelmetric.WithUnit(unit))
+	case metadata.KindUpDownCounter:
+		return meter.Float64ObservableUpDownCounter(name, otelmetric.WithDescription(description), otelmetric.WithUnit(unit))
+	default:
+		return meter.Float64ObservableGauge(name, otelmetric.WithDescription(description), otelmetric.WithUnit(unit))
+	}
+}
+
+// runtimeReader holds the samples of the runtime metrics read by a collection, each metric once.
+type runtimeReader struct {
+	// mu guards the samples, as collections may run concurrently, e.g. ForceFlush during a
+	// periodic export, while the samples are reused between them
+	mu      sync.Mutex
+	samples []metrics.Sample
+	index   map[string]int
+	// fresh is set once the callback of the instruments read the samples, so that the
+	// histogram producer, which runs after it in the same collection, does not read them again
+	fresh bool
+}
+
+// read reads all samples. The caller must hold mu.
+func (r *runtimeReader) read() {
+	metrics.Read(r.samples)
+	r.fresh = true
+}
+
+// readOnce reads all samples unless the callback just did, and consumes the fresh samples.
+// The caller must hold mu.
+func (r *runtimeReader) readOnce() {
+	if !r.fresh {
+		metrics.Read(r.samples)
+	}
+	r.fresh = false
+}
+
+// add returns the index of the sample of a runtime metric, adding it if needed. The
+// histograms may already be produced while the other metrics are registered.
+func (r *runtimeReader) add(name string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if i, ok := r.index[name]; ok {
+		return i
+	}
+	if r.index == nil {
+		r.index = make(map[string]int)
+	}
+	r.index[name] = len(r.samples)
+	r.samples = append(r.samples, metrics.Sample{Name: name})
+	return len(r.samples) - 1
+}
+
+// uint64 returns the value of a sample, or 0 when the runtime does not support the metric.
+func (r *runtimeReader) uint64(i int) int64 {
+	if r.samples[i].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return int64(r.samples[i].Value.Uint64())
+}
+
+// getMetricsOptions function to get metric labels
+func getMetricsOptions(metric metrics.Description) otelmetric.MeasurementOption {
+	return otelmetric.WithAttributeSet(getMetricsAttributes(metric))
+}
+
+// getMetricsAttributes function to get metric labels as attribute set
+func getMetricsAttributes(metric metrics.Description) attribute.Set {
+	tokens := strings.Split(metric.Name, "/")
+	if len(tokens) < 2 {
+		return *attribute.EmptySet()
+	}
+
+	nameTokens := strings.Split(tokens[len(tokens)-1], ":")
+	subsystem := metadata.GetMetricSubsystemName(metric)
+
+	// create a unique name for metric, that will be its primary key on the registry
+	return attribute.NewSet(
+		attribute.Key("Namespace").String(tokens[1]),
+		attribute.Key("Subsystem").String(subsystem),
+		attribute.Key("Units").String(nameTokens[1]),
+	)
+}
+
+// normalizePrometheusName function to normalize prometheus metric name
+func normalizeOtelName(name string) string {
+	normalizedName := strings.Replace(name, "/", "", 1)
+	normalizedName = strings.Replace(normalizedName, ":", "_", -1)
+	normalizedName = strings.TrimSpace(strings.ReplaceAll(normalizedName, "/", "_"))
+	return normalizedName
+}
+
+// runtimeHistograms exports the histograms of runtime/metrics, such as GC pauses and scheduler
+// latencies, as explicit bucket histograms. The metric API has no asynchronous histogram, so
+// they are handed to the reader as a producer. The runtime keeps the counts since the process
+// started; with delta temporality the counts of the previous collection are subtracted.
+type runtimeHistograms struct {
+	scope       instrumentation.Scope
+	temporality metricdata.Temporality
+	start       time.Time
+
+	descriptions []metrics.Description
+	names        []string
+	units        []string
+	bounds       [][]float64
+	attributes   []attribute.Set
+
+	// reader holds the samples, shared with the other runtime metrics, at indexes
+	reader  *runtimeReader
+	indexes []int
+	// previous holds the totals of the last collection for delta temporality
+	previous []histogramTotals
+}
+
+// histogramTotals are the folded counts of a runtime histogram.
+type histogramTotals struct {
+	count  uint64
+	sum    float64
+	counts []uint64
+}
+
+// newRuntimeHistograms returns the producer for the histograms among metricsMeta, or nil if
+// there are none. Their samples are added to reader.
+func newRuntimeHistograms(serviceName, naming string, temporality metricdata.Temporality, reader *runtimeReader, metricsMeta []metrics.Description) *runtimeHistograms {
+	h := &runtimeHistograms{
+		scope:       instrumentation.Scope{Name: runtimeScope(serviceName, naming)},
+		temporality: temporality,
+		start:       time.Now(),
+		reader:      reader,
+	}
+	for _, meta := range metricsMeta {
+		if meta.Kind != metrics.KindFloat64Histogram {
+			continue
+		}
+		h.descriptions = append(h.descriptions, meta)
+		switch semconvMetric, ok := metadata.GetSemconvMetric(meta.Name); {
+		case naming == metadata.NamingLegacy:
+			h.names = append(h.names, normalizeOtelName(meta.Name))
+			h.units = append(h.units, metadata.GetUnit(meta))
+			h.attributes = append(h.attributes, getMetricsAttributes(meta))
+		case ok:
+			h.names = append(h.names, semconvMetric.Name)
+			h.units = append(h.units, semconvMetric.Unit)
+			h.attributes = append(h.attributes, *attribute.EmptySet())
+		default:
+			h.names = append(h.names, metadata.GetSemconvName(meta))
+			h.units = append(h.units, metadata.GetUnit(meta))
+			h.attributes = append(h.attributes, *attribute.EmptySet())
+		}
+	}
+	if len(h.descriptions) == 0 {
+		return nil
+	}
+
+	// Bounds depend on the runtime buckets for unknown units, read them once
+	samples := make([]metrics.Sample, len(h.descriptions))
+	for i, meta := range h.descriptions {
+		samples[i].Name = meta.Name
+	}
+	metrics.Read(samples)
+	for i, sample := range samples {
+		h.bounds = append(h.bounds, metadata.HistogramBounds(h.descriptions[i], sample.Value.Float64Histogram()))
+		h.indexes = append(h.indexes, reader.add(sample.Name))
+	}
+	h.previous = make([]histogramTotals, len(h.descriptions))
+	return h
+}
+
+// Produce implements sdkmetric.Producer. The samples read by the callback of the other runtime
+// metrics in the same collection are reused.
+func (h *runtimeHistograms) Produce(context.Context) ([]metricdata.ScopeMetrics, error) {
+	h.reader.mu.Lock()
+	defer h.reader.mu.Unlock()
+
+	h.reader.readOnce()
+	now, start := time.Now(), h.start
+	if h.temporality == metricdata.DeltaTemporality {
+		// Delta data points cover the time since the last collection
+		h.start = now
+	}
+
+	data := make([]metricdata.Metrics, 0, len(h.indexes))
+	for i, index := range h.indexes {
+		sample := h.reader.samples[index]
+		if sample.Value.Kind() != metrics.KindFloat64Histogram {
+			continue
+		}
+		data = append(data, metricdata.Metrics{
+			Name:        h.names[i],
+			Description: h.descriptions[i].Description,
+			Unit:        h.units[i],
+			Data: metricdata.Histogram[float64]{
+				Temporality: h.temporality,
+				DataPoints:  []metricdata.HistogramDataPoint[float64]{h.dataPoint(i, sample.Value.Float64Histogram(), start, now)},
+			},
+		})
+	}
+	return []metricdata.ScopeMetrics{{Scope: h.scope, Metrics: data}}, nil
+}
+
+// dataPoint folds the runtime histogram of the i-th metric into a data point from start to
+// now. With delta temporality it holds the difference with the previous collection.
+func (h *runtimeHistograms) dataPoint(i int, histogram *metrics.Float64Histogram, start, now time.Time) metricdata.HistogramDataPoint[float64] {
+	counts := make([]uint64, len(h.bounds[i])+1)
+	count, sum := metadata.FoldHistogram(histogram, h.bounds[i], counts)
+	if h.temporality == metricdata.DeltaTemporality {
+		current := histogramTotals{count: count, sum: sum, counts: slices.Clone(counts)}
+		if previous := h.previous[i]; previous.counts != nil {
+			count -= previous.count
+			sum -= previous.sum
+			for j := range counts {
+				counts[j] -= previous.counts[j]
+			}
+		}
+		h.previous[i] = current
+	}
+
+	return metricdata.HistogramDataPoint[float64]{
+		Attributes:   h.attributes[i],
+		StartTime:    start,
+		Time:         now,
+		Count:        count,
+		Bounds:       h.bounds[i],
+		BucketCounts: counts,
+		Sum:          sum,
+	}
+}
+
+var _ sdkmetric.Producer = (*runtimeHistograms)(nil)
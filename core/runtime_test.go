@@ -0,0 +1,251 @@
+package core
+
+import (
+	"context"
+	"math"
+	"runtime/debug"
+	"runtime/metrics"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/idnandre/gobsv/internal/metadata"
+	otelmetric "go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// addMetricsPerRead registers the runtime metrics as before they shared a callback: one
+// callback per metric, each reading its own one-element sample slice. It is the baseline
+// of BenchmarkCollect.
+func addMetricsPerRead(provider *sdkmetric.MeterProvider, metricsMeta []metrics.Description) error {
+	meter := provider.Meter("baseline")
+	for _, meta := range metricsMeta {
+		if meta.Kind == metrics.KindFloat64Histogram {
+			continue
+		}
+		name := meta.Name
+		_, err := meter.Float64ObservableGauge(normalizeOtelName(name), otelmetric.WithFloat64Callback(
+			func(_ context.Context, o otelmetric.Float64Observer) error {
+				sample := make([]metrics.Sample, 1)
+				sample[0].Name = name
+				metrics.Read(sample)
+				o.Observe(metadata.GetSampleFloat(sample[0]), getMetricsOptions(meta))
+				return nil
+			}))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BenchmarkCollect measures a collection of the default runtime metrics, read with one
+// metrics.Read per metric before and with a single one now, histograms included.
+func BenchmarkCollect(b *testing.B) {
+	metricsMeta := metadata.Select(metrics.All(), metadata.PresetDefault, nil, nil)
+
+	for _, bm := range []struct {
+		name       string
+		histograms bool
+		register   func(*sdkmetric.MeterProvider, *runtimeReader) error
+	}{
+		{"read per metric", false, func(p *sdkmetric.MeterProvider, _ *runtimeReader) error {
+			return addMetricsPerRead(p, metricsMeta)
+		}},
+		{"single read", false, func(p *sdkmetric.MeterProvider, r *runtimeReader) error {
+			return addMetricsToOTEL(p, "bench", metadata.NamingLegacy, r, metricsMeta)
+		}},
+		{"single read semconv", false, func(p *sdkmetric.MeterProvider, r *runtimeReader) error {
+			return addMetricsToOTEL(p, "bench", metadata.NamingSemconv, r, metricsMeta)
+		}},
+		{"single read with histograms", true, func(p *sdkmetric.MeterProvider, r *runtimeReader) error {
+			return addMetricsToOTEL(p, "bench", metadata.NamingSemconv, r, metricsMeta)
+		}},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			reader, samples := newRuntimeReader(bm.histograms, metricsMeta)
+			provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+			defer provider.Shutdown(context.Background())
+			if err := bm.register(provider, samples); err != nil {
+				b.Fatal(err)
+			}
+
+			ctx := context.Background()
+			var rm metricdata.ResourceMetrics
+			b.ReportAllocs()
+			b.ResetTimer()
+			for range b.N {
+				if err := reader.Collect(ctx, &rm); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// newRuntimeReader returns a manual reader, producing the runtime histograms if asked, and
+// the samples to register the other runtime metrics with.
+func newRuntimeReader(histograms bool, metricsMeta []metrics.Description) (*sdkmetric.ManualReader, *runtimeReader) {
+	samples := &runtimeReader{}
+	var opts []sdkmetric.ManualReaderOption
+	if histograms {
+		h := newRuntimeHistograms("test", metadata.NamingSemconv, metricdata.CumulativeTemporality, samples, metricsMeta)
+		opts = append(opts, sdkmetric.WithProducer(h))
+	}
+	return sdkmetric.NewManualReader(opts...), samples
+}
+
+func TestCollect(t *testing.T) {
+	metricsMeta := metadata.Select(metrics.All(), metadata.PresetDefault, nil, nil)
+	reader, samples := newRuntimeReader(true, metricsMeta)
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer provider.Shutdown(context.Background())
+
+	if err := addMetricsToOTEL(provider, "test", metadata.NamingSemconv, samples, metricsMeta); err != nil {
+		t.Fatal(err)
+	}
+
+	for range 2 {
+		var rm metricdata.ResourceMetrics
+		if err := reader.Collect(context.Background(), &rm); err != nil {
+			t.Fatal(err)
+		}
+		names := make(map[string]bool)
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				names[m.Name] = true
+			}
+		}
+		for _, name := range []string{"go.goroutine.count", "go.schedule.duration"} {
+			if !names[name] {
+				t.Errorf("%s not collected", name)
+			}
+		}
+		// The histograms consumed the samples read by the callback
+		if samples.fresh {
+			t.Error("samples still fresh after a collection")
+		}
+	}
+}
+
+func TestGetSampleFloatUnsupported(t *testing.T) {
+	samples := []metrics.Sample{{Name: "/unknown/metric:bytes"}}
+	metrics.Read(samples)
+	if got := metadata.GetSampleFloat(samples[0]); got != 0 {
+		t.Errorf("GetSampleFloat() = %v, want 0 for an unsupported metric", got)
+	}
+}
+
+func TestMemoryLimit(t *testing.T) {
+	previous := debug.SetMemoryLimit(-1)
+	defer debug.SetMemoryLimit(previous)
+
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer provider.Shutdown(context.Background())
+	metricsMeta := metadata.Select(metrics.All(), metadata.PresetDefault, []string{"/gc/gomemlimit:bytes"}, nil)
+	if err := addMetricsToOTEL(provider, "test", metadata.NamingSemconv, &runtimeReader{}, metricsMeta); err != nil {
+		t.Fatal(err)
+	}
+
+	// limit returns the go.memory.limit data points collected, none when it is left out
+	limit := func() []metricdata.DataPoint[int64] {
+		t.Helper()
+		var rm metricdata.ResourceMetrics
+		if err := reader.Collect(context.Background(), &rm); err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range rm.ScopeMetrics[0].Metrics {
+			if m.Name == "go.memory.limit" {
+				return m.Data.(metricdata.Sum[int64]).DataPoints
+			}
+		}
+		return nil
+	}
+
+	debug.SetMemoryLimit(math.MaxInt64)
+	if points := limit(); len(points) != 0 {
+		t.Errorf("go.memory.limit = %v without a limit, want no data point", points)
+	}
+
+	debug.SetMemoryLimit(1 << 30)
+	if points := limit(); len(points) != 1 || points[0].Value != 1<<30 {
+		t.Errorf("go.memory.limit = %v, want 1073741824", points)
+	}
+}
+
+// schedLatencies returns the description of the scheduler latencies histogram.
+func schedLatencies(t *testing.T) []metrics.Description {
+	t.Helper()
+	for _, meta := range metrics.All() {
+		if meta.Name == "/sched/latencies:seconds" {
+			return []metrics.Description{meta}
+		}
+	}
+	t.Fatal("/sched/latencies:seconds not supported")
+	return nil
+}
+
+func TestHistogramDelta(t *testing.T) {
+	metricsMeta := schedLatencies(t)
+	h := newRuntimeHistograms("test", metadata.NamingSemconv, metricdata.DeltaTemporality, &runtimeReader{}, metricsMeta)
+	if h == nil || len(h.names) != 1 || h.names[0] != "go.schedule.duration" {
+		t.Fatalf("histograms = %+v, want go.schedule.duration", h)
+	}
+	// Fewer boundaries than the seconds ones, to spell out the expected counts
+	h.bounds[0] = []float64{0.001, 0.01}
+
+	buckets := []float64{math.Inf(-1), 0.001, 0.005, 0.01, math.Inf(1)}
+	now := time.Now()
+	for _, tt := range []struct {
+		name       string
+		cumulative []uint64
+		counts     []uint64
+		count      uint64
+		sum        float64
+	}{
+		{"first collection", []uint64{1, 2, 0, 1}, []uint64{1, 2, 1}, 4, 0.001 + 0.003*2 + 0.01},
+		{"second collection", []uint64{1, 5, 1, 3}, []uint64{0, 4, 2}, 6, 0.003*3 + 0.0075 + 0.01*2},
+		{"no new values", []uint64{1, 5, 1, 3}, []uint64{0, 0, 0}, 0, 0},
+	} {
+		histogram := &metrics.Float64Histogram{Counts: tt.cumulative, Buckets: buckets}
+		point := h.dataPoint(0, histogram, now, now)
+		if !slices.Equal(point.BucketCounts, tt.counts) || point.Count != tt.count || math.Abs(point.Sum-tt.sum) > 1e-12 {
+			t.Errorf("%s: counts = %v, count = %d, sum = %v, want %v, %d, %v",
+				tt.name, point.BucketCounts, point.Count, point.Sum, tt.counts, tt.count, tt.sum)
+		}
+	}
+}
+
+func TestProduceDelta(t *testing.T) {
+	metricsMeta := schedLatencies(t)
+	h := newRuntimeHistograms("test", metadata.NamingSemconv, metricdata.DeltaTemporality, &runtimeReader{}, metricsMeta)
+
+	// point returns the data point of the only histogram produced
+	point := func() metricdata.HistogramDataPoint[float64] {
+		t.Helper()
+		scopeMetrics, err := h.Produce(context.Background())
+		if err != nil {
+			t.Fatalf("Produce() error = %v", err)
+		}
+		histogram := scopeMetrics[0].Metrics[0].Data.(metricdata.Histogram[float64])
+		if histogram.Temporality != metricdata.DeltaTemporality {
+			t.Errorf("temporality = %v, want delta", histogram.Temporality)
+		}
+		return histogram.DataPoints[0]
+	}
+
+	first, second := point(), point()
+	// Successive delta data points are contiguous
+	if !second.StartTime.Equal(first.Time) {
+		t.Errorf("second start = %v, want the first end %v", second.StartTime, first.Time)
+	}
+	var total uint64
+	for _, count := range second.BucketCounts {
+		total += count
+	}
+	if total != second.Count {
+		t.Errorf("bucket counts %v add up to %d, want the count %d", second.BucketCounts, total, second.Count)
+	}
+}
@@ -0,0 +1,111 @@
+package core
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/idnandre/gobsv/internal/sampler"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// RateLimitingSampler returns a sampler that samples at most perSecond traces per second in
+// this process. Wrap it with sdktrace.ParentBased so that only new traces count against the limit
+// and child spans follow the decision of their parent.
+func RateLimitingSampler(perSecond float64) sdktrace.Sampler {
+	return sampler.RateLimiting(perSecond)
+}
+
+// SamplingRule selects the sampler for spans matching all of its non-empty fields.
+// The fields are matched against the attributes the middlewares set when starting a span.
+//
+// Route rules therefore need the route to be known before the middleware calls the next
+// handler: the gorilla and Fiber middlewares resolve it up front, the nethttp middleware only
+// when it wraps an http.ServeMux directly or is given a RouteExtractor that does. Spans
+// started without a route fall through to the next rule or the fallback sampler.
+type SamplingRule struct {
+	// Route matches http.route, either exactly or as a path.Match pattern such as "/checkout/*".
+	Route string
+	// Method matches http.request.method, or the legacy http.method, case-insensitively.
+	Method string
+	// EventSource matches faas.trigger, e.g. "http", "pubsub", "datasource" or "timer".
+	EventSource string
+	// Sampler decides for matching spans. When nil, the fallback sampler of RuleSampler decides.
+	Sampler sdktrace.Sampler
+}
+
+func (r SamplingRule) matches(attrs []attribute.KeyValue) bool {
+	route, method, source := "", "", ""
+	for _, attr := range attrs {
+		switch attr.Key {
+		case semconv.HTTPRouteKey:
+			route = attr.Value.AsString()
+		case semconv.HTTPRequestMethodKey, "http.method":
+			method = attr.Value.AsString()
+		case semconv.FaaSTriggerKey:
+			source = attr.Value.AsString()
+		}
+	}
+
+	if r.Route != "" && r.Route != route {
+		if ok, _ := path.Match(r.Route, route); !ok {
+			return false
+		}
+	}
+	if r.Method != "" && !strings.EqualFold(r.Method, method) {
+		return false
+	}
+	if r.EventSource != "" && r.EventSource != source {
+		return false
+	}
+	return true
+}
+
+type ruleSampler struct {
+	rules    []SamplingRule
+	fallback sdktrace.Sampler
+}
+
+// RuleSampler returns a sampler that delegates to the sampler of the first matching rule,
+// or to fallback when no rule matches. For example, to drop health checks and keep every
+// checkout while sampling 10% of the remaining traffic:
+//
+//	core.RuleSampler(sdktrace.TraceIDRatioBased(0.1),
+//		core.SamplingRule{Route: "/health", Sampler: sdktrace.NeverSample()},
+//		core.SamplingRule{Route: "/checkout/*", Sampler: sdktrace.AlwaysSample()},
+//	)
+//
+// A nil fallback samples like the SDK default, sdktrace.ParentBased(sdktrace.AlwaysSample()).
+func RuleSampler(fallback sdktrace.Sampler, rules ...SamplingRule) sdktrace.Sampler {
+	if fallback == nil {
+		fallback = sdktrace.ParentBased(sdktrace.AlwaysSample())
+	}
+	rules = slices.Clone(rules)
+	for i := range rules {
+		if rules[i].Sampler == nil {
+			rules[i].Sampler = fallback
+		}
+	}
+	return &ruleSampler{rules: rules, fallback: fallback}
+}
+
+func (s *ruleSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	for _, rule := range s.rules {
+		if rule.matches(p.Attributes) {
+			return rule.Sampler.ShouldSample(p)
+		}
+	}
+	return s.fallback.ShouldSample(p)
+}
+
+func (s *ruleSampler) Description() string {
+	descriptions := make([]string, 0, len(s.rules)+1)
+	for _, rule := range s.rules {
+		descriptions = append(descriptions, rule.Sampler.Description())
+	}
+	descriptions = append(descriptions, s.fallback.Description())
+	return fmt.Sprintf("RuleSampler{%s}", strings.Join(descriptions, ","))
+}
@@ -0,0 +1,51 @@
+package core
+
+import (
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+func TestRuleSampler(t *testing.T) {
+	sampler := RuleSampler(sdktrace.NeverSample(),
+		SamplingRule{Route: "/health", Sampler: sdktrace.NeverSample()},
+		SamplingRule{Route: "/checkout/*", Method: "post", Sampler: sdktrace.AlwaysSample()},
+		SamplingRule{EventSource: "pubsub", Sampler: sdktrace.AlwaysSample()},
+		SamplingRule{Route: "/nil"},
+	)
+
+	for _, tt := range []struct {
+		name  string
+		attrs []attribute.KeyValue
+		want  sdktrace.SamplingDecision
+	}{
+		{"health", []attribute.KeyValue{semconv.HTTPRoute("/health")}, sdktrace.Drop},
+		{"checkout", []attribute.KeyValue{semconv.HTTPRoute("/checkout/{id}"), semconv.HTTPRequestMethodPost}, sdktrace.RecordAndSample},
+		{"legacy method", []attribute.KeyValue{semconv.HTTPRoute("/checkout/{id}"), attribute.String("http.method", "POST")}, sdktrace.RecordAndSample},
+		{"checkout other method", []attribute.KeyValue{semconv.HTTPRoute("/checkout/{id}"), semconv.HTTPRequestMethodGet}, sdktrace.Drop},
+		{"event source", []attribute.KeyValue{semconv.FaaSTriggerPubsub}, sdktrace.RecordAndSample},
+		{"nil sampler uses fallback", []attribute.KeyValue{semconv.HTTPRoute("/nil")}, sdktrace.Drop},
+		{"no route", nil, sdktrace.Drop},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sampler.ShouldSample(sdktrace.SamplingParameters{Attributes: tt.attrs}).Decision
+			if got != tt.want {
+				t.Errorf("decision = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if got := sampler.Description(); got == "" {
+		t.Error("Description() is empty")
+	}
+}
+
+func TestRuleSamplerNilFallback(t *testing.T) {
+	sampler := RuleSampler(nil, SamplingRule{Route: "/health"})
+	got := sampler.ShouldSample(sdktrace.SamplingParameters{Attributes: []attribute.KeyValue{semconv.HTTPRoute("/health")}})
+	if got.Decision != sdktrace.RecordAndSample {
+		t.Errorf("decision = %v, want the SDK default to sample", got.Decision)
+	}
+}
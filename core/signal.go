@@ -0,0 +1,44 @@
+package core
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ShutdownOnSignal shuts the provider down when the process receives SIGTERM or SIGINT,
+// e.g. when Kubernetes terminates the pod, allowing at most timeout for the final export.
+// Afterwards the signal is raised again so the process terminates as it would have without
+// the hook. Applications that handle these signals themselves should call Shutdown from
+// their own handler instead. The returned function removes the hook.
+func (p *Provider) ShutdownOnSignal(timeout time.Duration) (stop func()) {
+	signals := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+
+	go func() {
+		select {
+		case sig := <-signals:
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			p.Shutdown(ctx)
+			cancel()
+
+			signal.Stop(signals)
+			if process, err := os.FindProcess(os.Getpid()); err == nil {
+				process.Signal(sig)
+			}
+		case <-done:
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(signals)
+			close(done)
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/idnandre/gobsv/internal/config"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestTemporalitySelector(t *testing.T) {
+	const (
+		cumulative = metricdata.CumulativeTemporality
+		delta      = metricdata.DeltaTemporality
+	)
+	kinds := []sdkmetric.InstrumentKind{
+		sdkmetric.InstrumentKindCounter,
+		sdkmetric.InstrumentKindHistogram,
+		sdkmetric.InstrumentKindUpDownCounter,
+		sdkmetric.InstrumentKindGauge,
+		sdkmetric.InstrumentKindObservableCounter,
+		sdkmetric.InstrumentKindObservableUpDownCounter,
+		sdkmetric.InstrumentKindObservableGauge,
+	}
+	for _, tt := range []struct {
+		temporality string
+		// want is the temporality of each of kinds, in order
+		want       []metricdata.Temporality
+		histograms metricdata.Temporality
+	}{
+		{config.TemporalityCumulative, []metricdata.Temporality{cumulative, cumulative, cumulative, cumulative, cumulative, cumulative, cumulative}, cumulative},
+		{"", []metricdata.Temporality{cumulative, cumulative, cumulative, cumulative, cumulative, cumulative, cumulative}, cumulative},
+		// Up-down counters stay cumulative, their deltas would be meaningless
+		{config.TemporalityDelta, []metricdata.Temporality{delta, delta, cumulative, delta, delta, cumulative, delta}, delta},
+		// Only the synchronous counters and histograms are delta, the runtime metrics stay cumulative
+		{config.TemporalityLowMemory, []metricdata.Temporality{delta, delta, cumulative, cumulative, cumulative, cumulative, cumulative}, cumulative},
+	} {
+		selector := temporalitySelector(tt.temporality)
+		for i, kind := range kinds {
+			if got := selector(kind); got != tt.want[i] {
+				t.Errorf("%q: temporality of %v = %v, want %v", tt.temporality, kind, got, tt.want[i])
+			}
+		}
+		if got := histogramTemporality(tt.temporality); got != tt.histograms {
+			t.Errorf("%q: runtime histogram temporality = %v, want %v", tt.temporality, got, tt.histograms)
+		}
+	}
+}
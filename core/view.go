@@ -0,0 +1,34 @@
+package core
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// HistogramBoundaries returns a view that aggregates the histograms named name, which may
+// contain "*" wildcards, into explicit buckets with the given upper bounds.
+func HistogramBoundaries(name string, boundaries ...float64) sdkmetric.View {
+	return sdkmetric.NewView(
+		sdkmetric.Instrument{Name: name},
+		sdkmetric.Stream{Aggregation: sdkmetric.AggregationExplicitBucketHistogram{Boundaries: boundaries}},
+	)
+}
+
+// ExponentialHistogram returns a view that aggregates the histograms named name into base-2
+// exponential buckets. maxSize bounds the number of buckets per sign and maxScale the resolution;
+// 160 and 20 are the defaults of the OpenTelemetry specification.
+func ExponentialHistogram(name string, maxSize, maxScale int32) sdkmetric.View {
+	return sdkmetric.NewView(
+		sdkmetric.Instrument{Name: name},
+		sdkmetric.Stream{Aggregation: sdkmetric.AggregationBase2ExponentialHistogram{MaxSize: maxSize, MaxScale: maxScale}},
+	)
+}
+
+// FilterAttributes returns a view that only keeps the given attributes on the metrics named
+// name, e.g. to drop high cardinality attributes.
+func FilterAttributes(name string, keys ...attribute.Key) sdkmetric.View {
+	return sdkmetric.NewView(
+		sdkmetric.Instrument{Name: name},
+		sdkmetric.Stream{AttributeFilter: attribute.NewAllowKeysFilter(keys...)},
+	)
+}
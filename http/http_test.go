@@ -0,0 +1,34 @@
+package http_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/idnandre/gobsv/http"
+	"github.com/idnandre/gobsv/internal/otlptest"
+	"github.com/idnandre/gobsv/internal/presettest"
+)
+
+func TestPreset(t *testing.T) {
+	presettest.Run(t, http.New)
+}
+
+func TestResourceHasNoFunction(t *testing.T) {
+	t.Setenv("AWS_LAMBDA_FUNCTION_NAME", "checkout")
+
+	ctx := context.Background()
+	c := otlptest.NewCollector(t)
+	p, err := http.New(ctx, presettest.Options(c)...)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	_, span := p.Tracer("test").Start(ctx, "span")
+	span.End()
+	if err := p.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+
+	if got, ok := c.ResourceAttributes()["faas.name"]; ok {
+		t.Errorf("faas.name = %q, want no function attributes", got)
+	}
+}
@@ -1,83 +1,165 @@
 package fiber
 
 import (
-	"encoding/json"
+	"errors"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/attribute"
+	obsv "github.com/idnandre/gobsv/http"
+	"github.com/idnandre/gobsv/internal/httpconv"
 	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/trace"
 )
 
-type responseStatus struct {
-	Status int `json:"status"`
+const instrumentationName = "github.com/idnandre/gobsv/http/middleware/fiber"
+
+// StatusCoder is implemented by errors that carry the HTTP status code they respond with.
+// It is looked up in the chain of errors with errors.As, so wrapped errors are supported.
+// Set ErrorHandler as the app's ErrorHandler to respond with it.
+type StatusCoder interface {
+	StatusCode() int
 }
 
-func (r *responseStatus) Error() string {
-	return "error"
+// ErrorHandler responds like fiber.DefaultErrorHandler, with the error message as plain text,
+// but also takes the status code from StatusCoder errors.
+func ErrorHandler(c *fiber.Ctx, err error) error {
+	c.Set(fiber.HeaderContentType, fiber.MIMETextPlainCharsetUTF8)
+	return c.Status(errorStatusCode(err)).SendString(err.Error())
 }
 
-type responseCode struct {
-	Code int `json:"code"`
+type config struct {
+	handleErrors bool
 }
 
-func (r *responseCode) Error() string {
-	return "error"
+// Option configures TraceMiddleware.
+type Option func(*config)
+
+// WithErrorHandling makes the middleware respond to the errors of the next handlers with the
+// app's ErrorHandler, so that the status code a custom ErrorHandler responds with is recorded.
+// The middleware then returns the ErrorHandler result instead of the error, which outer
+// middlewares no longer see. Should the ErrorHandler fail, the status code is taken from its
+// error.
+func WithErrorHandling() Option {
+	return func(c *config) {
+		c.handleErrors = true
+	}
 }
 
-func TraceMiddleware() fiber.Handler {
+// TraceMiddleware returns a fiber middleware that traces requests with the given provider
+// and records the HTTP server metrics listed in package http.
+// Errors returned by the next handlers are returned as is, for the app to respond to them.
+// Their status code is the code of a *fiber.Error, else of a StatusCoder in their chain,
+// else 500, as fiber.DefaultErrorHandler and ErrorHandler respond; see WithErrorHandling
+// for custom error handlers.
+// Errors returned by the handlers are recorded as exception events. They set the span status
+// to Error, like 5xx responses, unless they respond with a status code below 500, e.g.
+// fiber.ErrNotFound.
+func TraceMiddleware(provider *obsv.Provider, opts ...Option) fiber.Handler {
+	cfg := config{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	tracer := provider.Tracer(instrumentationName)
+	propagator := provider.Propagator()
+	server := httpconv.NewServer(provider.Meter(instrumentationName))
+	semconvMode := string(provider.HTTPSemconv())
+	stackTraces := provider.ErrorStackTraces()
+	var routes routeTable
+
 	return func(c *fiber.Ctx) error {
-		routePattern := ""
-		currentPath := string(c.Context().Path())
-		for _, route := range c.App().GetRoutes() {
-			if fiber.RoutePatternMatch(currentPath, route.Path) {
-				routePattern = route.Path
-				break
-			}
+		start := time.Now()
+		method, currentPath := c.Method(), string(c.Context().Path())
+		// Resolved before starting the span so that samplers can match on http.route
+		routePattern := routes.lookup(c.App(), method, currentPath)
+
+		request := httpconv.ServerRequest{
+			Method:          method,
+			Scheme:          c.Protocol(),
+			Path:            currentPath,
+			Query:           string(c.Context().URI().QueryString()),
+			Route:           routePattern,
+			Host:            string(c.Context().Host()),
+			UserAgent:       string(c.Context().UserAgent()),
+			ClientAddress:   c.IP(),
+			ProtocolVersion: httpconv.ProtocolVersion(string(c.Request().Header.Protocol())),
 		}
 
-		ctx := otel.GetTextMapPropagator().Extract(c.Context(), propagation.HeaderCarrier(c.GetReqHeaders()))
-		ctx, span := otel.Tracer("").Start(ctx, string(c.Context().Method())+" "+routePattern, trace.WithSpanKind(trace.SpanKindServer))
-		defer span.End()
+		ctx := propagator.Extract(c.Context(), propagation.HeaderCarrier(c.GetReqHeaders()))
+		ctx, span := tracer.Start(ctx, httpconv.SpanName(method, routePattern),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(httpconv.StartAttributes(semconvMode, request)...),
+		)
 
 		c.SetUserContext(ctx)
+		server.Begin(ctx, method, c.Protocol())
 
+		// The request is recorded and the span ended once the handlers return, or panic:
+		// the panic is then recorded as error and propagated
+		var handlerErr error
 		statusCode := 0
-		err := c.Next()
-
-		if len(c.Context().Response.Body()) > 0 {
-			statusCode = c.Context().Response.StatusCode()
-		} else {
-			resp, ok := err.(interface{})
-			if ok {
-				respJSON, _ := json.Marshal(resp)
-				rspStatus := &responseStatus{}
-				rspCode := &responseCode{}
-				json.Unmarshal(respJSON, &rspStatus)
-				json.Unmarshal(respJSON, &rspCode)
-
-				if rspStatus.Status > 0 {
-					statusCode = rspStatus.Status
-				} else if rspCode.Code > 0 {
-					statusCode = rspCode.Code
-				}
+		finished := false
+		defer func() {
+			recordedErr, recovered := handlerErr, any(nil)
+			if !finished {
+				recovered = recover()
+				recordedErr = &httpconv.PanicError{Value: recovered}
+				statusCode = fiber.StatusInternalServerError
 			}
-		}
 
-		span.SetAttributes(
-			attribute.String("span.kind", "server"),
-			attribute.String("resource.name", string(c.Context().Method())+" "+string(c.Context().Path())),
-			attribute.String("http.method", string(c.Context().Method())),
-			attribute.String("http.url", routePattern),
-			attribute.String("http.raw.query", string(c.Context().URI().QueryString())),
-			attribute.String("http.route", routePattern),
-			attribute.String("http.target", routePattern),
-			attribute.String("http.useragent", string(c.Context().UserAgent())),
-			attribute.String("http.host", string(c.Context().Host())),
-			attribute.Int("http.status_code", statusCode),
-		)
+			if matched, ok := routes.matched(c, method, currentPath, routePattern); ok {
+				routePattern = matched
+				request.Route = routePattern
+				span.SetName(httpconv.SpanName(method, routePattern))
+			}
+
+			server.End(ctx, httpconv.Request{
+				Method:       method,
+				Scheme:       request.Scheme,
+				Route:        routePattern,
+				StatusCode:   statusCode,
+				ErrorType:    httpconv.ErrorType(statusCode, recordedErr),
+				RequestSize:  int64(len(c.Request().Body())),
+				ResponseSize: int64(len(c.Response().Body())),
+				Start:        start,
+			})
+
+			span.SetAttributes(httpconv.EndAttributes(semconvMode, request, statusCode)...)
+			httpconv.SetSpanError(span, statusCode, recordedErr, stackTraces)
+			span.End()
+
+			if !finished {
+				panic(recovered)
+			}
+		}()
+
+		handlerErr = c.Next()
+		err := handlerErr
+		if err != nil && cfg.handleErrors {
+			// Respond as the app would once the error is returned, the response
+			// then holds the final status code
+			err = c.App().Config().ErrorHandler(c, err)
+		}
+		statusCode = c.Response().StatusCode()
+		if err != nil {
+			// The app responds to the returned error once the middlewares return
+			statusCode = errorStatusCode(err)
+		}
+		finished = true
 
 		return err
 	}
 }
+
+// errorStatusCode returns the status code an error responds with: the code of a *fiber.Error,
+// else of a StatusCoder in its chain, else 500.
+func errorStatusCode(err error) int {
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		return fiberErr.Code
+	}
+	var statusCoder StatusCoder
+	if errors.As(err, &statusCoder) {
+		return statusCoder.StatusCode()
+	}
+	return fiber.StatusInternalServerError
+}
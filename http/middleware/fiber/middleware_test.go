@@ -0,0 +1,338 @@
+package fiber
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/idnandre/gobsv/core"
+	obsv "github.com/idnandre/gobsv/http"
+	"github.com/idnandre/gobsv/internal/otlptest"
+	"github.com/idnandre/gobsv/internal/presettest"
+	"github.com/valyala/fasthttp"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+func newProvider(t *testing.T, opts ...obsv.Option) (*obsv.Provider, *otlptest.Collector) {
+	t.Helper()
+	return presettest.NewProvider(t, append([]obsv.Option{core.WithHTTPSemconv(core.HTTPSemconvNew)}, opts...)...)
+}
+
+// serve sends the requests to app and returns the spans recorded for them.
+func serve(t *testing.T, app *fiber.App, p *obsv.Provider, c *otlptest.Collector, requests ...*fasthttp.Request) []map[string]string {
+	t.Helper()
+	for _, req := range requests {
+		var ctx fasthttp.RequestCtx
+		req.CopyTo(&ctx.Request)
+		app.Handler()(&ctx)
+	}
+	return presettest.Spans(t, p, c)
+}
+
+func request(method, uri string, headers ...string) *fasthttp.Request {
+	req := &fasthttp.Request{}
+	req.Header.SetMethod(method)
+	req.SetRequestURI(uri)
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	return req
+}
+
+func TestRouteOfScopedMiddleware(t *testing.T) {
+	p, c := newProvider(t)
+	app := fiber.New()
+	app.Use(TraceMiddleware(p))
+	app.Use("/users", func(c *fiber.Ctx) error {
+		if c.Get(fiber.HeaderAuthorization) == "" {
+			return c.SendStatus(fiber.StatusUnauthorized)
+		}
+		return c.Next()
+	})
+	app.Get("/users/:id", func(c *fiber.Ctx) error {
+		return c.SendString(c.Params("id"))
+	})
+
+	spans := serve(t, app, p, c,
+		request(fiber.MethodGet, "/users/1"),
+		request(fiber.MethodGet, "/users/1", fiber.HeaderAuthorization, "Bearer token"),
+		request(fiber.MethodGet, "/users/1"),
+	)
+	if len(spans) != 3 {
+		t.Fatalf("got %d spans, want 3", len(spans))
+	}
+	for i, want := range []string{"401", "200", "401"} {
+		if got := spans[i]["http.response.status_code"]; got != want {
+			t.Errorf("span %d: status = %s, want %s", i, got, want)
+		}
+		if got := spans[i]["name"]; got != "GET /users/:id" {
+			t.Errorf("span %d: name = %q, want GET /users/:id", i, got)
+		}
+		if got := spans[i]["http.route"]; got != "/users/:id" {
+			t.Errorf("span %d: http.route = %q, want /users/:id", i, got)
+		}
+	}
+}
+
+func TestRouteByMethod(t *testing.T) {
+	p, c := newProvider(t)
+	app := fiber.New()
+	app.Use(TraceMiddleware(p))
+	app.Post("/orders/:id", func(c *fiber.Ctx) error { return nil })
+	app.Get("/orders/latest", func(c *fiber.Ctx) error { return nil })
+	app.Get("/orders/:id", func(c *fiber.Ctx) error { return nil })
+
+	spans := serve(t, app, p, c,
+		request(fiber.MethodGet, "/orders/latest"),
+		request(fiber.MethodGet, "/orders/1"),
+		request(fiber.MethodPost, "/orders/1"),
+		request(fiber.MethodGet, "/unknown"),
+	)
+	want := []string{"GET /orders/latest", "GET /orders/:id", "POST /orders/:id", "GET"}
+	if len(spans) != len(want) {
+		t.Fatalf("got %d spans, want %d", len(spans), len(want))
+	}
+	for i, name := range want {
+		if got := spans[i]["name"]; got != name {
+			t.Errorf("span %d: name = %q, want %q", i, got, name)
+		}
+	}
+}
+
+func TestRouteOfNext(t *testing.T) {
+	p, c := newProvider(t)
+	app := fiber.New()
+	app.Use(TraceMiddleware(p))
+	app.Get("/files/:name", func(c *fiber.Ctx) error {
+		if c.Params("name") == "next" {
+			return c.Next()
+		}
+		return nil
+	})
+	app.Get("/files/*", func(c *fiber.Ctx) error { return nil })
+
+	spans := serve(t, app, p, c, request(fiber.MethodGet, "/files/next"))
+	if len(spans) != 1 || spans[0]["name"] != "GET /files/*" {
+		t.Errorf("spans = %v, want GET /files/*", spans)
+	}
+}
+
+// BenchmarkTraceMiddleware measures the overhead of the middleware on an app with 500 routes,
+// for paths whose route is cached and for paths seen for the first time.
+func BenchmarkTraceMiddleware(b *testing.B) {
+	const routes = 500
+
+	newApp := func(middleware bool) fasthttp.RequestHandler {
+		app := fiber.New()
+		if middleware {
+			// A nil provider records nothing, leaving the cost of the middleware itself
+			app.Use(TraceMiddleware(nil))
+		}
+		for i := range routes {
+			app.Get(fmt.Sprintf("/resource%d/:id", i), func(c *fiber.Ctx) error {
+				return c.SendStatus(fiber.StatusNoContent)
+			})
+		}
+		return app.Handler()
+	}
+
+	run := func(b *testing.B, handler fasthttp.RequestHandler, uri func(i int) string) {
+		var ctx fasthttp.RequestCtx
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := range b.N {
+			ctx.Request.Reset()
+			ctx.Response.Reset()
+			ctx.Request.Header.SetMethod(fiber.MethodGet)
+			ctx.Request.SetRequestURI(uri(i))
+			handler(&ctx)
+		}
+	}
+	cached := func(i int) string { return fmt.Sprintf("/resource%d/42", i%routes) }
+	uncached := func(i int) string { return fmt.Sprintf("/resource%d/%d", i%routes, i) }
+
+	b.Run("without middleware", func(b *testing.B) {
+		run(b, newApp(false), cached)
+	})
+	b.Run("cached", func(b *testing.B) {
+		run(b, newApp(true), cached)
+	})
+	b.Run("uncached", func(b *testing.B) {
+		run(b, newApp(true), uncached)
+	})
+}
+
+func TestRouteLookupPrefixes(t *testing.T) {
+	app := fiber.New()
+	for _, path := range []string{"/users/:id?", "/Teams", "/files/*", "/orders/"} {
+		app.Get(path, func(c *fiber.Ctx) error { return nil })
+	}
+
+	var routes routeTable
+	for path, want := range map[string]string{
+		"/users":     "/users/:id?",
+		"/users/1":   "/users/:id?",
+		"/teams":     "/Teams",
+		"/files/a/b": "/files/*",
+		"/orders":    "/orders/",
+		"/unknown/1": "",
+	} {
+		if got := routes.lookup(app, fiber.MethodGet, path); got != want {
+			t.Errorf("lookup(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestRouteRules(t *testing.T) {
+	p, c := newProvider(t, core.WithSampler(core.RuleSampler(sdktrace.NeverSample(),
+		core.SamplingRule{Route: "/checkout/:id", Sampler: sdktrace.AlwaysSample()},
+	)))
+	app := fiber.New()
+	app.Use(TraceMiddleware(p))
+	app.Get("/checkout/:id", func(c *fiber.Ctx) error { return nil })
+	app.Get("/catalog/:id", func(c *fiber.Ctx) error { return nil })
+
+	spans := serve(t, app, p, c,
+		request(fiber.MethodGet, "/checkout/1"),
+		request(fiber.MethodGet, "/catalog/1"),
+		request(fiber.MethodGet, "/checkout/2"),
+	)
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want the 2 checkout requests", len(spans))
+	}
+	for _, span := range spans {
+		if span["name"] != "GET /checkout/:id" {
+			t.Errorf("name = %q, want GET /checkout/:id", span["name"])
+		}
+	}
+}
+
+func TestReturnedErrors(t *testing.T) {
+	p, c := newProvider(t)
+	app := fiber.New()
+	app.Use(TraceMiddleware(p))
+	app.Get("/missing", func(c *fiber.Ctx) error { return fiber.ErrNotFound })
+	app.Get("/unavailable", func(c *fiber.Ctx) error { return fiber.ErrServiceUnavailable })
+	app.Get("/failing", func(c *fiber.Ctx) error { return errors.New("failing") })
+	serve(t, app, p, c,
+		request(fiber.MethodGet, "/missing"),
+		request(fiber.MethodGet, "/unavailable"),
+		request(fiber.MethodGet, "/failing"),
+	)
+
+	durations := make(map[string]map[string]string)
+	for _, rm := range c.Metrics() {
+		for _, sm := range rm.GetScopeMetrics() {
+			for _, m := range sm.GetMetrics() {
+				if m.GetName() != "http.server.request.duration" {
+					continue
+				}
+				for _, dp := range m.GetHistogram().GetDataPoints() {
+					attrs := otlptest.Attributes(dp.GetAttributes())
+					durations[attrs["http.route"]] = attrs
+				}
+			}
+		}
+	}
+
+	tests := []struct {
+		route      string
+		statusCode string
+		failed     bool
+		errorType  string
+	}{
+		// Client errors are recorded, but do not fail the request
+		{route: "/missing", statusCode: "404"},
+		{route: "/unavailable", statusCode: "503", failed: true, errorType: "*fiber.Error"},
+		{route: "/failing", statusCode: "500", failed: true, errorType: "*errors.errorString"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.route, func(t *testing.T) {
+			var span *tracepb.Span
+			for _, s := range c.SpanList() {
+				if s.GetName() == "GET "+tt.route {
+					span = s
+				}
+			}
+			if span == nil {
+				t.Fatalf("no span named GET %s", tt.route)
+			}
+
+			attrs := otlptest.Attributes(span.GetAttributes())
+			if attrs["http.response.status_code"] != tt.statusCode {
+				t.Errorf("http.response.status_code = %q, want %q", attrs["http.response.status_code"], tt.statusCode)
+			}
+			if failed := span.GetStatus().GetCode() == tracepb.Status_STATUS_CODE_ERROR; failed != tt.failed {
+				t.Errorf("span status is Error = %v, want %v", failed, tt.failed)
+			}
+			if attrs["error.type"] != tt.errorType {
+				t.Errorf("error.type = %q, want %q", attrs["error.type"], tt.errorType)
+			}
+			if events := span.GetEvents(); len(events) != 1 || events[0].GetName() != "exception" {
+				t.Errorf("events = %v, want one exception", events)
+			}
+
+			metric, ok := durations[tt.route]
+			if !ok {
+				t.Fatalf("no request duration recorded for %s", tt.route)
+			}
+			if metric["error.type"] != tt.errorType {
+				t.Errorf("request duration error.type = %q, want %q", metric["error.type"], tt.errorType)
+			}
+		})
+	}
+}
+
+type teapotError struct{}
+
+func (teapotError) Error() string   { return "teapot" }
+func (teapotError) StatusCode() int { return fiber.StatusTeapot }
+
+func TestErrorHandling(t *testing.T) {
+	// Responds with 409 to every error, which only WithErrorHandling sees
+	conflict := func(c *fiber.Ctx, err error) error {
+		return c.Status(fiber.StatusConflict).SendString(err.Error())
+	}
+
+	tests := []struct {
+		name       string
+		opts       []Option
+		err        error
+		statusCode string
+		outerErr   bool
+	}{
+		{name: "fiber.Error", err: fiber.ErrNotFound, statusCode: "404", outerErr: true},
+		{name: "StatusCoder", err: fmt.Errorf("wrapped: %w", teapotError{}), statusCode: "418", outerErr: true},
+		{name: "other", err: errors.New("failing"), statusCode: "500", outerErr: true},
+		{name: "WithErrorHandling", opts: []Option{WithErrorHandling()}, err: fiber.ErrNotFound, statusCode: "409"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, c := newProvider(t)
+			app := fiber.New(fiber.Config{ErrorHandler: conflict})
+			var outerErr error
+			app.Use(func(c *fiber.Ctx) error {
+				outerErr = c.Next()
+				return outerErr
+			})
+			app.Use(TraceMiddleware(p, tt.opts...))
+			app.Get("/", func(c *fiber.Ctx) error { return tt.err })
+
+			spans := serve(t, app, p, c, request(fiber.MethodGet, "/"))
+			if len(spans) != 1 {
+				t.Fatalf("got %d spans, want 1", len(spans))
+			}
+			if got := spans[0]["http.response.status_code"]; got != tt.statusCode {
+				t.Errorf("http.response.status_code = %q, want %q", got, tt.statusCode)
+			}
+			if tt.outerErr && !errors.Is(outerErr, tt.err) {
+				t.Errorf("outer middleware error = %v, want %v", outerErr, tt.err)
+			}
+			if !tt.outerErr && outerErr != nil {
+				t.Errorf("outer middleware error = %v, want nil once handled", outerErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package fiber
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// maxCachedRoutes bounds the route cache, which is keyed by request paths and so grows with
+// the path parameters seen. It is cleared once full.
+const maxCachedRoutes = 4096
+
+// routeTable resolves the route template of requests from the routes registered with a
+// method, leaving out the ones registered with Use: a path-scoped middleware such as
+// app.Use("/users", auth) matches "/users/1" as well, but is not its route.
+type routeTable struct {
+	routes atomic.Pointer[routeSet]
+
+	mu sync.RWMutex
+	// cache maps "METHOD path" to the route template resolved for it.
+	cache map[string]string
+}
+
+// routeSet is a snapshot of the routes of an app.
+type routeSet struct {
+	// handlers is the app's HandlersCount when the snapshot was taken, to take a new one once
+	// routes are added.
+	handlers uint32
+	config   fiber.Config
+	byMethod map[string][]route
+	paths    map[string]struct{}
+}
+
+type route struct {
+	path string
+	// prefix is the static start of the path, which requests must start with to match.
+	prefix string
+}
+
+// set returns the routes of app, taking a new snapshot and clearing the cache once routes
+// were added since the last one.
+func (t *routeTable) set(app *fiber.App) *routeSet {
+	if rs := t.routes.Load(); rs != nil && rs.handlers == app.HandlersCount() {
+		return rs
+	}
+
+	rs := &routeSet{
+		handlers: app.HandlersCount(),
+		config:   app.Config(),
+		byMethod: make(map[string][]route),
+		paths:    make(map[string]struct{}),
+	}
+	for _, r := range app.GetRoutes(true) {
+		// Parameters may be optional and trailing slashes are ignored without StrictRouting,
+		// so the slash in front of them is left out as well.
+		prefix := r.Path
+		if i := strings.IndexAny(prefix, ":*+"); i >= 0 {
+			prefix = prefix[:i]
+		}
+		prefix = strings.TrimSuffix(prefix, "/")
+		if !rs.config.CaseSensitive {
+			prefix = strings.ToLower(prefix)
+		}
+		rs.byMethod[r.Method] = append(rs.byMethod[r.Method], route{path: r.Path, prefix: prefix})
+		rs.paths[r.Method+" "+r.Path] = struct{}{}
+	}
+
+	t.mu.Lock()
+	t.cache = make(map[string]string)
+	t.mu.Unlock()
+	t.routes.Store(rs)
+	return rs
+}
+
+// lookup returns the route template of the request, the first route of the method that
+// matches the path in the order fiber matches them. It is cached by method and path.
+func (t *routeTable) lookup(app *fiber.App, method, path string) string {
+	rs := t.set(app)
+
+	key := method + " " + path
+	t.mu.RLock()
+	cached, ok := t.cache[key]
+	t.mu.RUnlock()
+	if ok {
+		return cached
+	}
+
+	match := path
+	if !rs.config.CaseSensitive {
+		match = strings.ToLower(path)
+	}
+	resolved := ""
+	for _, r := range rs.byMethod[method] {
+		if strings.HasPrefix(match, r.prefix) && fiber.RoutePatternMatch(path, r.path, rs.config) {
+			resolved = r.path
+			break
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.cache) >= maxCachedRoutes {
+		clear(t.cache)
+	}
+	t.cache[key] = resolved
+	return resolved
+}
+
+// matched returns the route fiber matched last, c.Route(), when it differs from resolved
+// and is a route of the method matching the path. That is the case when handlers pass
+// requests on to later routes with Next.
+func (t *routeTable) matched(c *fiber.Ctx, method, path, resolved string) (string, bool) {
+	last := c.Route().Path
+	if last == resolved {
+		return "", false
+	}
+
+	rs := t.set(c.App())
+	if _, ok := rs.paths[method+" "+last]; ok && fiber.RoutePatternMatch(path, last, rs.config) {
+		return last, true
+	}
+	return "", false
+}
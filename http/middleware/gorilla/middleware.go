@@ -4,52 +4,33 @@ import (
 	"net/http"
 
 	"github.com/gorilla/mux"
-
-	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/propagation"
-	"go.opentelemetry.io/otel/trace"
+	obsv "github.com/idnandre/gobsv/http"
+	"github.com/idnandre/gobsv/internal/httpmiddleware"
 )
 
-type responseWriter struct {
-	http.ResponseWriter
-	statusCode int
-}
+const instrumentationName = "github.com/idnandre/gobsv/http/middleware/gorilla"
 
-func newResponseWriter(w http.ResponseWriter) *responseWriter {
-	return &responseWriter{w, http.StatusOK}
+// TraceMiddleware returns a gorilla/mux middleware that traces requests with the given provider
+// and records the HTTP server metrics listed in package http.
+// Panics and 5xx responses set the span status to Error.
+func TraceMiddleware(provider *obsv.Provider) mux.MiddlewareFunc {
+	return middleware(httpmiddleware.NewConfig(provider, instrumentationName, routeTemplate))
 }
 
-func (rw *responseWriter) WriteHeader(code int) {
-	rw.statusCode = code
-	rw.ResponseWriter.WriteHeader(code)
+// middleware returns the middleware handling requests with cfg.
+func middleware(cfg httpmiddleware.Config) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return httpmiddleware.Handler(cfg, next)
+	}
 }
 
-func TraceMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		route := mux.CurrentRoute(r)
-		path, _ := route.GetPathTemplate()
-
-		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
-		ctx, span := otel.Tracer("").Start(ctx, r.Method+" "+path, trace.WithSpanKind(trace.SpanKindServer))
-		defer span.End()
-
-		newRequest := r.WithContext(ctx)
-		newResponseWriter := newResponseWriter(w)
-
-		next.ServeHTTP(newResponseWriter, newRequest)
-
-		span.SetAttributes(
-			attribute.String("span.kind", "server"),
-			attribute.String("resource.name", r.Method+" "+r.URL.Path),
-			attribute.String("http.method", r.Method),
-			attribute.String("http.url", path),
-			attribute.String("http.raw.query", r.URL.RawQuery),
-			attribute.String("http.route", path),
-			attribute.String("http.target", path),
-			attribute.String("http.useragent", r.UserAgent()),
-			attribute.String("http.host", r.Host),
-			attribute.Int("http.status_code", newResponseWriter.statusCode),
-		)
-	})
+// routeTemplate returns the path template of the route matched by the gorilla router,
+// or "" outside of one.
+func routeTemplate(r *http.Request) string {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return ""
+	}
+	path, _ := route.GetPathTemplate()
+	return path
 }
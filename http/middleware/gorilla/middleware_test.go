@@ -0,0 +1,63 @@
+package gorilla
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/idnandre/gobsv/internal/middlewaretest"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestRouterMetrics(t *testing.T) {
+	rec := middlewaretest.NewRecorder(t)
+
+	router := mux.NewRouter()
+	router.Use(middleware(rec.Config(routeTemplate)))
+	router.HandleFunc("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, "created")
+	}).Methods(http.MethodPost)
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/users/1", strings.NewReader("name")))
+
+	if names := rec.SpanNames(); len(names) != 1 || names[0] != "POST /users/{id}" {
+		t.Errorf("spans = %v, want [POST /users/{id}]", names)
+	}
+	middlewaretest.CheckServerMetrics(t, rec.Metrics(t), middlewaretest.ServerRequest{
+		Attributes: []attribute.KeyValue{
+			attribute.String("http.request.method", http.MethodPost),
+			attribute.String("url.scheme", "http"),
+			attribute.Int("http.response.status_code", http.StatusCreated),
+			attribute.String("http.route", "/users/{id}"),
+		},
+		RequestSize:  int64(len("name")),
+		ResponseSize: int64(len("created")),
+	})
+}
+
+func TestOutsideRouter(t *testing.T) {
+	rec := middlewaretest.NewRecorder(t)
+
+	// Without a mux.Router there is no current route
+	handler := middleware(rec.Config(routeTemplate))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/1", nil))
+
+	if names := rec.SpanNames(); len(names) != 1 || names[0] != "GET" {
+		t.Errorf("spans = %v, want [GET]", names)
+	}
+	middlewaretest.CheckServerMetrics(t, rec.Metrics(t), middlewaretest.ServerRequest{
+		Attributes: []attribute.KeyValue{
+			attribute.String("http.request.method", http.MethodGet),
+			attribute.String("url.scheme", "http"),
+			attribute.Int("http.response.status_code", http.StatusOK),
+		},
+		ResponseSize: int64(len("ok")),
+	})
+}
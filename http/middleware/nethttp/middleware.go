@@ -0,0 +1,87 @@
+// Package nethttp provides a tracing middleware for any net/http router, such as the
+// http.ServeMux patterns of Go 1.22.
+package nethttp
+
+import (
+	"net/http"
+	"strings"
+
+	obsv "github.com/idnandre/gobsv/http"
+	"github.com/idnandre/gobsv/internal/httpmiddleware"
+)
+
+const instrumentationName = "github.com/idnandre/gobsv/http/middleware/nethttp"
+
+// RouteExtractor returns the route template of a request, e.g. "/users/{id}", or "" when unknown.
+type RouteExtractor func(*http.Request) string
+
+type config struct {
+	route RouteExtractor
+}
+
+// Option configures TraceMiddleware.
+type Option func(*config)
+
+// WithRouteExtractor sets how the route template is read from requests, for routers that
+// keep it elsewhere than http.Request.Pattern.
+func WithRouteExtractor(extractor RouteExtractor) Option {
+	return func(c *config) {
+		c.route = extractor
+	}
+}
+
+// TraceMiddleware returns a middleware that traces requests with the given provider and records
+// the HTTP server metrics listed in package http.
+// Panics and 5xx responses set the span status to Error.
+//
+// The span name and http.route come from the pattern http.ServeMux matched, or from the
+// extractor set with WithRouteExtractor. When the middleware wraps an http.ServeMux directly,
+// the pattern is looked up with ServeMux.Handler before the span starts, so that sampling
+// rules on the route apply. Otherwise it is only known once the next handler returns, too
+// late for samplers.
+func TraceMiddleware(provider *obsv.Provider, opts ...Option) func(http.Handler) http.Handler {
+	c := config{}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	base := httpmiddleware.NewConfig(provider, instrumentationName, c.route)
+
+	return func(next http.Handler) http.Handler {
+		cfg := base
+		if cfg.Route == nil {
+			cfg.Route = PatternRoute
+			if mux, ok := next.(*http.ServeMux); ok {
+				cfg.Route = muxRoute(mux)
+			}
+		}
+		return httpmiddleware.Handler(cfg, next)
+	}
+}
+
+// muxRoute returns a RouteExtractor that looks up the pattern mux matches for requests
+// it did not dispatch yet.
+func muxRoute(mux *http.ServeMux) RouteExtractor {
+	return func(r *http.Request) string {
+		if r.Pattern != "" {
+			return PatternRoute(r)
+		}
+		_, pattern := mux.Handler(r)
+		return patternPath(pattern)
+	}
+}
+
+// PatternRoute returns the path of the http.ServeMux pattern that matched the request,
+// without the method and host, e.g. "/users/{id}" for "GET example.com/users/{id}".
+func PatternRoute(r *http.Request) string {
+	return patternPath(r.Pattern)
+}
+
+func patternPath(pattern string) string {
+	if _, path, ok := strings.Cut(pattern, " "); ok {
+		pattern = strings.TrimSpace(path)
+	}
+	if i := strings.IndexByte(pattern, '/'); i > 0 {
+		pattern = pattern[i:]
+	}
+	return pattern
+}
@@ -0,0 +1,71 @@
+package nethttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/idnandre/gobsv/core"
+	obsv "github.com/idnandre/gobsv/http"
+	"github.com/idnandre/gobsv/internal/otlptest"
+	"github.com/idnandre/gobsv/internal/presettest"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// serve sends the requests to handler and returns the names of the spans recorded for them.
+func serve(t *testing.T, handler http.Handler, p *obsv.Provider, c *otlptest.Collector, requests ...*http.Request) []string {
+	t.Helper()
+	for _, r := range requests {
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	return presettest.SpanNames(t, p, c)
+}
+
+func TestRouteRulesWithServeMux(t *testing.T) {
+	p, c := presettest.NewProvider(t, core.WithSampler(core.RuleSampler(sdktrace.NeverSample(),
+		core.SamplingRule{Route: "/checkout/{id}", Sampler: sdktrace.AlwaysSample()},
+	)))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /checkout/{id}", func(http.ResponseWriter, *http.Request) {})
+	mux.HandleFunc("GET /catalog/{id}", func(http.ResponseWriter, *http.Request) {})
+
+	names := serve(t, TraceMiddleware(p)(mux), p, c,
+		httptest.NewRequest(http.MethodGet, "/checkout/1", nil),
+		httptest.NewRequest(http.MethodGet, "/catalog/1", nil),
+	)
+	if len(names) != 1 || names[0] != "GET /checkout/{id}" {
+		t.Errorf("spans = %v, want [GET /checkout/{id}]", names)
+	}
+}
+
+func TestRouteAfterHandler(t *testing.T) {
+	p, c := presettest.NewProvider(t)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET example.com/users/{id}", func(http.ResponseWriter, *http.Request) {})
+	// The middleware does not wrap the mux itself, the route is read once it dispatched.
+	handler := TraceMiddleware(p)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.ServeHTTP(w, r)
+	}))
+
+	names := serve(t, handler, p, c,
+		httptest.NewRequest(http.MethodGet, "http://example.com/users/1", nil),
+		httptest.NewRequest(http.MethodGet, "http://example.com/unknown", nil),
+	)
+	if len(names) != 2 || names[0] != "GET /users/{id}" || names[1] != "GET" {
+		t.Errorf("spans = %v, want [GET /users/{id} GET]", names)
+	}
+}
+
+func TestRouteExtractor(t *testing.T) {
+	p, c := presettest.NewProvider(t)
+	handler := TraceMiddleware(p, WithRouteExtractor(func(*http.Request) string {
+		return "/custom"
+	}))(http.NotFoundHandler())
+
+	names := serve(t, handler, p, c, httptest.NewRequest(http.MethodGet, "/anything", nil))
+	if len(names) != 1 || names[0] != "GET /custom" {
+		t.Errorf("spans = %v, want [GET /custom]", names)
+	}
+}
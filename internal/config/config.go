@@ -0,0 +1,225 @@
+// Package config holds the pipeline settings of the core package
+// and loads them from the standard OTEL_* environment variables.
+package config
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/idnandre/gobsv/internal/httpconv"
+	"github.com/idnandre/gobsv/internal/metadata"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Supported OTLP transport protocols, as named by OTEL_EXPORTER_OTLP_PROTOCOL.
+const (
+	ProtocolHTTPProtobuf = "http/protobuf"
+	ProtocolGRPC         = "grpc"
+)
+
+// Receivers used when no endpoint is configured, per protocol.
+const (
+	DefaultHTTPEndpoint = "localhost:4318"
+	DefaultGRPCEndpoint = "localhost:4317"
+)
+
+// CompressionGzip is the only supported export compression.
+const CompressionGzip = "gzip"
+
+// Metric temporality preferences, as named by OTEL_EXPORTER_OTLP_METRICS_TEMPORALITY_PREFERENCE.
+const (
+	TemporalityCumulative = "cumulative"
+	TemporalityDelta      = "delta"
+	TemporalityLowMemory  = "lowmemory"
+)
+
+// Retry configures how failed exports are retried. It mirrors the RetryConfig of the OTLP exporters.
+type Retry struct {
+	Enabled         bool
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	MaxElapsedTime  time.Duration
+}
+
+// Config describes the telemetry pipeline to build.
+type Config struct {
+	// Protocol is either ProtocolHTTPProtobuf or ProtocolGRPC.
+	Protocol string
+	// Endpoint is the host and port of the OTLP receiver.
+	Endpoint string
+	// URLPath is an optional path prefix in front of the signal specific path, e.g. "/otlp".
+	URLPath string
+	// Insecure disables TLS towards the receiver. It is ignored once any TLS setting is present.
+	Insecure bool
+	// TLSConfig is the base TLS configuration, completed with the CA bundle, client
+	// certificate and server name below.
+	TLSConfig      *tls.Config
+	CAFile         string
+	ClientCertFile string
+	ClientKeyFile  string
+	ServerName     string
+	// Headers are sent with every export request.
+	Headers map[string]string
+	// HeadersFunc, when set, is called before every export and its result is merged over Headers.
+	HeadersFunc func(context.Context) (map[string]string, error)
+	// Compression is either empty or CompressionGzip.
+	Compression string
+	// Retry overrides the retry behaviour of the exporters when set.
+	Retry *Retry
+
+	ServiceName        string
+	ServiceVersion     string
+	ServiceNamespace   string
+	Environment        string
+	ResourceAttributes []attribute.KeyValue
+	// Detectors add platform specific attributes to the resource.
+	Detectors []resource.Detector
+
+	Sampler sdktrace.Sampler
+	// Propagator, when set, is used as is. Otherwise PropagatorNames are resolved with ParsePropagators.
+	Propagator      propagation.TextMapPropagator
+	PropagatorNames []string
+	MetricInterval  time.Duration
+	MetricTimeout   time.Duration
+	// Temporality is one of the Temporality* preferences, empty means cumulative.
+	Temporality string
+	Views       []sdkmetric.View
+
+	// RuntimeMetrics is the preset of Go runtime metrics to export, see metadata.Select.
+	RuntimeMetrics        string
+	RuntimeMetricsInclude []string
+	RuntimeMetricsExclude []string
+	// RuntimeMetricsNaming is metadata.NamingSemconv or metadata.NamingLegacy.
+	RuntimeMetricsNaming string
+
+	// ProcessMetrics exports the process.* and container.* metrics of the proc and cgroup file systems.
+	ProcessMetrics bool
+
+	// HTTPSemconv selects the span attributes of the middlewares, one of the httpconv.Semconv* modes.
+	HTTPSemconv string
+	// ErrorStackTraces adds stack traces to the exception events the middlewares record.
+	ErrorStackTraces bool
+
+	// Global registers the providers and propagator as the OpenTelemetry globals.
+	Global bool
+}
+
+// Default returns the configuration used when neither environment variables nor options are set.
+func Default() Config {
+	return Config{
+		Protocol:             ProtocolHTTPProtobuf,
+		Insecure:             true,
+		PropagatorNames:      []string{"tracecontext", "baggage"},
+		RuntimeMetrics:       metadata.PresetDefault,
+		RuntimeMetricsNaming: metadata.NamingSemconv,
+		HTTPSemconv:          httpconv.SemconvOld,
+		Global:               true,
+	}
+}
+
+// Load resolves the configuration in the following order, each step overriding the previous one:
+//
+//  1. built-in defaults,
+//  2. standard OTEL_* environment variables,
+//  3. the given options.
+func Load[O ~func(*Config)](opts []O) (Config, error) {
+	cfg := Default()
+	if err := cfg.applyEnv(); err != nil {
+		return Config{}, err
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if err := cfg.validate(); err != nil {
+		return Config{}, err
+	}
+	if err := cfg.parseEndpoint(); err != nil {
+		return Config{}, err
+	}
+	if err := cfg.buildTLS(); err != nil {
+		return Config{}, err
+	}
+	if cfg.Propagator == nil {
+		propagator, err := ParsePropagators(cfg.PropagatorNames)
+		if err != nil {
+			return Config{}, err
+		}
+		cfg.Propagator = propagator
+	}
+	return cfg, nil
+}
+
+func (c *Config) validate() error {
+	switch c.Protocol {
+	case ProtocolHTTPProtobuf:
+		if c.Endpoint == "" {
+			c.Endpoint = DefaultHTTPEndpoint
+		}
+	case ProtocolGRPC:
+		if c.Endpoint == "" {
+			c.Endpoint = DefaultGRPCEndpoint
+		}
+	default:
+		return fmt.Errorf("unsupported protocol %q", c.Protocol)
+	}
+
+	switch c.Compression {
+	case "", CompressionGzip:
+	default:
+		return fmt.Errorf("unsupported compression %q", c.Compression)
+	}
+
+	switch c.Temporality {
+	case "", TemporalityCumulative, TemporalityDelta, TemporalityLowMemory:
+	default:
+		return fmt.Errorf("unsupported temporality %q", c.Temporality)
+	}
+
+	if !metadata.IsPreset(c.RuntimeMetrics) {
+		return fmt.Errorf("unsupported runtime metrics preset %q", c.RuntimeMetrics)
+	}
+	switch c.RuntimeMetricsNaming {
+	case metadata.NamingSemconv, metadata.NamingLegacy:
+	default:
+		return fmt.Errorf("unsupported runtime metrics naming %q", c.RuntimeMetricsNaming)
+	}
+
+	switch c.HTTPSemconv {
+	case httpconv.SemconvOld, httpconv.SemconvNew, httpconv.SemconvBoth:
+	default:
+		return fmt.Errorf("unsupported HTTP semantic conventions mode %q", c.HTTPSemconv)
+	}
+	return nil
+}
+
+// parseEndpoint accepts either "host:port" or a URL such as "https://collector:4318/otlp".
+// A URL also decides whether TLS is used and may carry a path prefix.
+func (c *Config) parseEndpoint() error {
+	if !strings.Contains(c.Endpoint, "://") {
+		return nil
+	}
+
+	u, err := url.Parse(c.Endpoint)
+	if err != nil {
+		return fmt.Errorf("invalid endpoint %q: %w", c.Endpoint, err)
+	}
+	switch u.Scheme {
+	case "http":
+		c.Insecure = true
+	case "https":
+		c.Insecure = false
+	default:
+		return fmt.Errorf("invalid endpoint %q: unsupported scheme %q", c.Endpoint, u.Scheme)
+	}
+	c.Endpoint = u.Host
+	c.URLPath = strings.TrimSuffix(u.Path, "/")
+	return nil
+}
@@ -0,0 +1,270 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/idnandre/gobsv/internal/httpconv"
+	"github.com/idnandre/gobsv/internal/sampler"
+	"go.opentelemetry.io/contrib/propagators/aws/xray"
+	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/contrib/propagators/jaeger"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// Environment variables defined by the OpenTelemetry specification.
+const (
+	envProtocol           = "OTEL_EXPORTER_OTLP_PROTOCOL"
+	envEndpoint           = "OTEL_EXPORTER_OTLP_ENDPOINT"
+	envCompression        = "OTEL_EXPORTER_OTLP_COMPRESSION"
+	envHeaders            = "OTEL_EXPORTER_OTLP_HEADERS"
+	envCertificate        = "OTEL_EXPORTER_OTLP_CERTIFICATE"
+	envClientCertificate  = "OTEL_EXPORTER_OTLP_CLIENT_CERTIFICATE"
+	envClientKey          = "OTEL_EXPORTER_OTLP_CLIENT_KEY"
+	envServiceName        = "OTEL_SERVICE_NAME"
+	envResourceAttributes = "OTEL_RESOURCE_ATTRIBUTES"
+	envTracesSampler      = "OTEL_TRACES_SAMPLER"
+	envTracesSamplerArg   = "OTEL_TRACES_SAMPLER_ARG"
+	envPropagators        = "OTEL_PROPAGATORS"
+	envMetricInterval     = "OTEL_METRIC_EXPORT_INTERVAL"
+	envMetricTimeout      = "OTEL_METRIC_EXPORT_TIMEOUT"
+	envTemporality        = "OTEL_EXPORTER_OTLP_METRICS_TEMPORALITY_PREFERENCE"
+	envSemconvOptIn       = "OTEL_SEMCONV_STABILITY_OPT_IN"
+)
+
+// applyEnv overrides the configuration with the OTEL_* environment variables that are set.
+func (c *Config) applyEnv() error {
+	if v := os.Getenv(envProtocol); v != "" {
+		c.Protocol = v
+	}
+
+	if v := os.Getenv(envEndpoint); v != "" {
+		c.Endpoint = v
+	}
+
+	switch v := os.Getenv(envCompression); v {
+	case "":
+	case "none":
+		c.Compression = ""
+	default:
+		c.Compression = v
+	}
+
+	if v := os.Getenv(envHeaders); v != "" {
+		pairs, err := parseKeyValues(v)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", envHeaders, err)
+		}
+		c.Headers = make(map[string]string, len(pairs))
+		for _, pair := range pairs {
+			c.Headers[pair[0]] = pair[1]
+		}
+	}
+
+	if v := os.Getenv(envCertificate); v != "" {
+		c.CAFile = v
+	}
+	if v := os.Getenv(envClientCertificate); v != "" {
+		c.ClientCertFile = v
+	}
+	if v := os.Getenv(envClientKey); v != "" {
+		c.ClientKeyFile = v
+	}
+
+	if v := os.Getenv(envResourceAttributes); v != "" {
+		pairs, err := parseKeyValues(v)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", envResourceAttributes, err)
+		}
+		attrs := make([]attribute.KeyValue, 0, len(pairs))
+		for _, pair := range pairs {
+			attrs = append(attrs, attribute.String(pair[0], pair[1]))
+		}
+		c.ResourceAttributes = attrs
+		// The other attributes stay in ResourceAttributes so that WithResourceAttributes replaces them.
+		for _, attr := range attrs {
+			if attr.Key == semconv.ServiceNameKey {
+				c.ServiceName = attr.Value.AsString()
+			}
+		}
+	}
+
+	// OTEL_SERVICE_NAME takes precedence over service.name in OTEL_RESOURCE_ATTRIBUTES.
+	if v := os.Getenv(envServiceName); v != "" {
+		c.ServiceName = v
+	}
+
+	if v := os.Getenv(envTracesSampler); v != "" {
+		sampler, err := ParseSampler(v, os.Getenv(envTracesSamplerArg))
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", envTracesSampler, err)
+		}
+		c.Sampler = sampler
+	}
+
+	if v := os.Getenv(envPropagators); v != "" {
+		names := strings.Split(v, ",")
+		if _, err := ParsePropagators(names); err != nil {
+			return fmt.Errorf("invalid %s: %w", envPropagators, err)
+		}
+		c.PropagatorNames = names
+	}
+
+	if v := os.Getenv(envMetricInterval); v != "" {
+		ms, err := strconv.Atoi(v)
+		if err != nil || ms <= 0 {
+			return fmt.Errorf("invalid %s: %q is not a positive number of milliseconds", envMetricInterval, v)
+		}
+		c.MetricInterval = time.Duration(ms) * time.Millisecond
+	}
+
+	if v := os.Getenv(envMetricTimeout); v != "" {
+		ms, err := strconv.Atoi(v)
+		if err != nil || ms <= 0 {
+			return fmt.Errorf("invalid %s: %q is not a positive number of milliseconds", envMetricTimeout, v)
+		}
+		c.MetricTimeout = time.Duration(ms) * time.Millisecond
+	}
+
+	if v := os.Getenv(envTemporality); v != "" {
+		c.Temporality = strings.ToLower(v)
+	}
+
+	// Only the http domain is relevant, "http/dup" wins over "http"
+	for _, domain := range strings.Split(os.Getenv(envSemconvOptIn), ",") {
+		switch strings.TrimSpace(domain) {
+		case "http":
+			if c.HTTPSemconv != httpconv.SemconvBoth {
+				c.HTTPSemconv = httpconv.SemconvNew
+			}
+		case "http/dup":
+			c.HTTPSemconv = httpconv.SemconvBoth
+		}
+	}
+
+	return nil
+}
+
+// parseKeyValues parses the W3C Baggage like "key1=value1,key2=value2" format used by
+// OTEL_RESOURCE_ATTRIBUTES and OTEL_EXPORTER_OTLP_HEADERS. Values may be percent-encoded.
+func parseKeyValues(s string) ([][2]string, error) {
+	var pairs [][2]string
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("missing key or value in %q", pair)
+		}
+		value, err := url.PathUnescape(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %q: %w", key, err)
+		}
+		pairs = append(pairs, [2]string{key, value})
+	}
+	return pairs, nil
+}
+
+// defaultRateLimit is the number of traces per second sampled by the rate limiting samplers
+// when OTEL_TRACES_SAMPLER_ARG is not set.
+const defaultRateLimit = 100
+
+// ParseSampler returns the sampler named by an OTEL_TRACES_SAMPLER value.
+// arg is the OTEL_TRACES_SAMPLER_ARG value, used as ratio by the ratio based samplers and as
+// traces per second by the rate limiting samplers.
+func ParseSampler(name, arg string) (sdktrace.Sampler, error) {
+	ratio := func() (float64, error) {
+		if arg == "" {
+			return 1, nil
+		}
+		r, err := strconv.ParseFloat(arg, 64)
+		if err != nil || r < 0 || r > 1 {
+			return 0, fmt.Errorf("sampler argument %q is not a ratio between 0 and 1", arg)
+		}
+		return r, nil
+	}
+	rate := func() (float64, error) {
+		if arg == "" {
+			return defaultRateLimit, nil
+		}
+		r, err := strconv.ParseFloat(arg, 64)
+		if err != nil || r <= 0 {
+			return 0, fmt.Errorf("sampler argument %q is not a positive number of traces per second", arg)
+		}
+		return r, nil
+	}
+
+	switch strings.TrimSpace(name) {
+	case "always_on":
+		return sdktrace.AlwaysSample(), nil
+	case "always_off":
+		return sdktrace.NeverSample(), nil
+	case "traceidratio":
+		r, err := ratio()
+		if err != nil {
+			return nil, err
+		}
+		return sdktrace.TraceIDRatioBased(r), nil
+	case "parentbased_always_on":
+		return sdktrace.ParentBased(sdktrace.AlwaysSample()), nil
+	case "parentbased_always_off":
+		return sdktrace.ParentBased(sdktrace.NeverSample()), nil
+	case "parentbased_traceidratio":
+		r, err := ratio()
+		if err != nil {
+			return nil, err
+		}
+		return sdktrace.ParentBased(sdktrace.TraceIDRatioBased(r)), nil
+	case "ratelimiting":
+		r, err := rate()
+		if err != nil {
+			return nil, err
+		}
+		return sampler.RateLimiting(r), nil
+	case "parentbased_ratelimiting":
+		r, err := rate()
+		if err != nil {
+			return nil, err
+		}
+		return sdktrace.ParentBased(sampler.RateLimiting(r)), nil
+	default:
+		return nil, fmt.Errorf("unsupported sampler %q", name)
+	}
+}
+
+// ParsePropagators returns the composite propagator for the names used by OTEL_PROPAGATORS:
+// tracecontext, baggage, b3, b3multi, jaeger, xray and none.
+func ParsePropagators(names []string) (propagation.TextMapPropagator, error) {
+	var propagators []propagation.TextMapPropagator
+	for _, name := range names {
+		switch strings.TrimSpace(name) {
+		case "tracecontext":
+			propagators = append(propagators, propagation.TraceContext{})
+		case "baggage":
+			propagators = append(propagators, propagation.Baggage{})
+		case "b3":
+			propagators = append(propagators, b3.New(b3.WithInjectEncoding(b3.B3SingleHeader)))
+		case "b3multi":
+			propagators = append(propagators, b3.New(b3.WithInjectEncoding(b3.B3MultipleHeader)))
+		case "jaeger":
+			propagators = append(propagators, jaeger.Jaeger{})
+		case "xray":
+			propagators = append(propagators, xray.Propagator{})
+		case "none":
+			return propagation.NewCompositeTextMapPropagator(), nil
+		case "":
+		default:
+			return nil, fmt.Errorf("unsupported propagator %q", name)
+		}
+	}
+	return propagation.NewCompositeTextMapPropagator(propagators...), nil
+}
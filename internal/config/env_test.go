@@ -0,0 +1,187 @@
+package config
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/idnandre/gobsv/internal/httpconv"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestHTTPSemconvOptIn(t *testing.T) {
+	for value, want := range map[string]string{
+		"":               httpconv.SemconvOld,
+		"database":       httpconv.SemconvOld,
+		"http":           httpconv.SemconvNew,
+		"database, http": httpconv.SemconvNew,
+		"http/dup":       httpconv.SemconvBoth,
+		"http,http/dup":  httpconv.SemconvBoth,
+		"http/dup,http":  httpconv.SemconvBoth,
+	} {
+		t.Run(value, func(t *testing.T) {
+			t.Setenv(envSemconvOptIn, value)
+			cfg, err := Load[func(*Config)](nil)
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if cfg.HTTPSemconv != want {
+				t.Errorf("HTTPSemconv = %q, want %q", cfg.HTTPSemconv, want)
+			}
+		})
+	}
+}
+
+func TestParsePropagators(t *testing.T) {
+	const (
+		traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+		spanID  = "00f067aa0ba902b7"
+	)
+	for _, tt := range []struct {
+		names   []string
+		headers map[string]string
+		// extracted is false when the propagators must ignore the headers
+		extracted bool
+		// injected is the header the span context is injected into
+		injected string
+	}{
+		{
+			names:     []string{"tracecontext"},
+			headers:   map[string]string{"traceparent": "00-" + traceID + "-" + spanID + "-01"},
+			extracted: true,
+			injected:  "Traceparent",
+		},
+		{
+			names:     []string{"b3"},
+			headers:   map[string]string{"b3": traceID + "-" + spanID + "-1"},
+			extracted: true,
+			injected:  "B3",
+		},
+		{
+			names:     []string{"b3multi"},
+			headers:   map[string]string{"X-B3-TraceId": traceID, "X-B3-SpanId": spanID, "X-B3-Sampled": "1"},
+			extracted: true,
+			injected:  "X-B3-Traceid",
+		},
+		{
+			names:     []string{"jaeger"},
+			headers:   map[string]string{"uber-trace-id": traceID + ":" + spanID + ":0:1"},
+			extracted: true,
+			injected:  "Uber-Trace-Id",
+		},
+		{
+			names:     []string{"xray"},
+			headers:   map[string]string{"X-Amzn-Trace-Id": "Root=1-4bf92f35-77b34da6a3ce929d0e0e4736;Parent=" + spanID + ";Sampled=1"},
+			extracted: true,
+			injected:  "X-Amzn-Trace-Id",
+		},
+		{
+			names:     []string{" tracecontext", "baggage ", "xray"},
+			headers:   map[string]string{"X-Amzn-Trace-Id": "Root=1-4bf92f35-77b34da6a3ce929d0e0e4736;Parent=" + spanID + ";Sampled=1"},
+			extracted: true,
+			injected:  "Traceparent",
+		},
+		{
+			names:   []string{"tracecontext"},
+			headers: map[string]string{"b3": traceID + "-" + spanID + "-1"},
+		},
+		{
+			names:   []string{"tracecontext", "none"},
+			headers: map[string]string{"traceparent": "00-" + traceID + "-" + spanID + "-01"},
+		},
+	} {
+		propagator, err := ParsePropagators(tt.names)
+		if err != nil {
+			t.Errorf("ParsePropagators(%q) error = %v", tt.names, err)
+			continue
+		}
+
+		carrier := propagation.HeaderCarrier(http.Header{})
+		for key, value := range tt.headers {
+			carrier.Set(key, value)
+		}
+		sc := trace.SpanContextFromContext(propagator.Extract(context.Background(), carrier))
+		if !tt.extracted {
+			if sc.IsValid() {
+				t.Errorf("ParsePropagators(%q) extracted %v from %v", tt.names, sc, tt.headers)
+			}
+			continue
+		}
+		if sc.TraceID().String() != traceID || sc.SpanID().String() != spanID || !sc.IsSampled() {
+			t.Errorf("ParsePropagators(%q) extracted %s/%s sampled %v, want %s/%s sampled",
+				tt.names, sc.TraceID(), sc.SpanID(), sc.IsSampled(), traceID, spanID)
+		}
+
+		injected := propagation.HeaderCarrier(http.Header{})
+		propagator.Inject(trace.ContextWithSpanContext(context.Background(), sc), injected)
+		if injected.Get(tt.injected) == "" {
+			t.Errorf("ParsePropagators(%q) injected %v, want %s", tt.names, injected.Keys(), tt.injected)
+		}
+	}
+}
+
+func TestParsePropagatorsUnsupported(t *testing.T) {
+	if _, err := ParsePropagators([]string{"tracecontext", "ottrace"}); err == nil {
+		t.Error("ParsePropagators() accepted ottrace")
+	}
+}
+
+func TestParseRateLimitingSampler(t *testing.T) {
+	for _, tt := range []struct {
+		name, arg   string
+		description string
+	}{
+		{"ratelimiting", "", "RateLimitingSampler{100}"},
+		{"ratelimiting", "2.5", "RateLimitingSampler{2.5}"},
+		{" ratelimiting ", "0.2", "RateLimitingSampler{0.2}"},
+		{"parentbased_ratelimiting", "", "ParentBased{root:RateLimitingSampler{100},"},
+		{"parentbased_ratelimiting", "10", "ParentBased{root:RateLimitingSampler{10},"},
+	} {
+		s, err := ParseSampler(tt.name, tt.arg)
+		if err != nil {
+			t.Errorf("ParseSampler(%q, %q) error = %v", tt.name, tt.arg, err)
+			continue
+		}
+		if got := s.Description(); !strings.HasPrefix(got, tt.description) {
+			t.Errorf("ParseSampler(%q, %q) = %s, want %s", tt.name, tt.arg, got, tt.description)
+		}
+	}
+
+	for _, name := range []string{"ratelimiting", "parentbased_ratelimiting"} {
+		for _, arg := range []string{"0", "-1", "fast"} {
+			if _, err := ParseSampler(name, arg); err == nil {
+				t.Errorf("ParseSampler(%q, %q) accepted the rate", name, arg)
+			}
+		}
+	}
+}
+
+func TestParentBasedRateLimiting(t *testing.T) {
+	s, err := ParseSampler("parentbased_ratelimiting", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	decision := func(ctx context.Context) sdktrace.SamplingDecision {
+		return s.ShouldSample(sdktrace.SamplingParameters{ParentContext: ctx, TraceID: trace.TraceID{1}}).Decision
+	}
+
+	root := context.Background()
+	if decision(root) != sdktrace.RecordAndSample || decision(root) != sdktrace.Drop {
+		t.Error("root spans are not limited to one per second")
+	}
+
+	// Children follow their sampled parent whatever the rate
+	parent := trace.ContextWithRemoteSpanContext(root, trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{1},
+		TraceFlags: trace.FlagsSampled,
+	}))
+	for range 3 {
+		if decision(parent) != sdktrace.RecordAndSample {
+			t.Fatal("child of a sampled parent dropped")
+		}
+	}
+}
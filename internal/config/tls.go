@@ -0,0 +1,57 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// hasTLS reports whether any TLS setting was configured.
+func (c *Config) hasTLS() bool {
+	return c.TLSConfig != nil || c.CAFile != "" || c.ClientCertFile != "" || c.ClientKeyFile != "" || c.ServerName != ""
+}
+
+// buildTLS loads the CA bundle and client key pair and folds them together with the
+// server name into TLSConfig.
+func (c *Config) buildTLS() error {
+	if !c.hasTLS() {
+		return nil
+	}
+
+	tlsConfig := &tls.Config{}
+	if c.TLSConfig != nil {
+		tlsConfig = c.TLSConfig.Clone()
+	}
+
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read CA certificate: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no valid certificate found in %s", c.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if c.ClientCertFile != "" || c.ClientKeyFile != "" {
+		if c.ClientCertFile == "" || c.ClientKeyFile == "" {
+			return fmt.Errorf("client certificate and client key must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(c.ClientCertFile, c.ClientKeyFile)
+		if err != nil {
+			return fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = append(tlsConfig.Certificates, cert)
+	}
+
+	if c.ServerName != "" {
+		tlsConfig.ServerName = c.ServerName
+	}
+
+	c.TLSConfig = tlsConfig
+	c.Insecure = false
+	return nil
+}
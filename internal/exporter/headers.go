@@ -0,0 +1,189 @@
+// Package exporter provides wrappers around the OTLP exporters built by the core package.
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sync"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// HeadersFunc returns the headers to send with the next export, e.g. a refreshed bearer token.
+type HeadersFunc func(context.Context) (map[string]string, error)
+
+// refresher keeps an exporter built with the latest headers and rebuilds it when they change.
+type refresher[E interface{ Shutdown(context.Context) error }] struct {
+	static  map[string]string
+	dynamic HeadersFunc
+	build   func(context.Context, map[string]string) (E, error)
+
+	mu      sync.Mutex
+	current *lease[E]
+	headers map[string]string
+}
+
+// lease counts the exports still running on an exporter, so that an exporter replaced
+// while another export uses it is only shut down once that export returns.
+type lease[E interface{ Shutdown(context.Context) error }] struct {
+	exporter E
+	refs     int
+	retired  bool
+}
+
+func newRefresher[E interface{ Shutdown(context.Context) error }](ctx context.Context, static map[string]string, dynamic HeadersFunc, build func(context.Context, map[string]string) (E, error)) (*refresher[E], error) {
+	r := &refresher[E]{
+		static:  static,
+		dynamic: dynamic,
+		build:   build,
+	}
+
+	// Build right away so that configuration errors surface at startup. When the headers
+	// cannot be fetched yet, start with the static ones and let the first export retry.
+	headers := static
+	if merged, err := r.merge(ctx); err == nil {
+		headers, r.headers = merged, merged
+	}
+	current, err := build(ctx, headers)
+	if err != nil {
+		return nil, err
+	}
+	r.current = &lease[E]{exporter: current}
+	return r, nil
+}
+
+// merge returns the static headers overridden by the ones currently returned by HeadersFunc.
+func (r *refresher[E]) merge(ctx context.Context) (map[string]string, error) {
+	dynamic, err := r.dynamic(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to refresh export headers: %w", err)
+	}
+
+	headers := maps.Clone(r.static)
+	if headers == nil {
+		headers = make(map[string]string, len(dynamic))
+	}
+	maps.Copy(headers, dynamic)
+	return headers, nil
+}
+
+// acquire returns an exporter that sends the headers currently returned by HeadersFunc.
+// The caller must pass the lease to release once the export is done.
+func (r *refresher[E]) acquire(ctx context.Context) (*lease[E], error) {
+	headers, err := r.merge(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.headers == nil || !maps.Equal(headers, r.headers) {
+		next, err := r.build(ctx, headers)
+		if err != nil {
+			return nil, err
+		}
+		previous := r.current
+		r.current, r.headers = &lease[E]{exporter: next}, headers
+		previous.retired = true
+		if previous.refs == 0 {
+			previous.exporter.Shutdown(ctx)
+		}
+	}
+
+	r.current.refs++
+	return r.current, nil
+}
+
+// release ends an export started with acquire and shuts the exporter down if it was
+// replaced in the meantime and no other export is using it.
+func (r *refresher[E]) release(ctx context.Context, l *lease[E]) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	l.refs--
+	if l.retired && l.refs == 0 {
+		l.exporter.Shutdown(context.WithoutCancel(ctx))
+	}
+}
+
+func (r *refresher[E]) shutdown(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current.exporter.Shutdown(ctx)
+}
+
+type spanExporter struct {
+	*refresher[sdktrace.SpanExporter]
+}
+
+// NewSpanExporter returns a span exporter that calls headers before every export and
+// rebuilds the underlying exporter through build whenever the headers change.
+func NewSpanExporter(ctx context.Context, static map[string]string, headers HeadersFunc, build func(context.Context, map[string]string) (sdktrace.SpanExporter, error)) (sdktrace.SpanExporter, error) {
+	r, err := newRefresher(ctx, static, headers, build)
+	if err != nil {
+		return nil, err
+	}
+	return &spanExporter{r}, nil
+}
+
+func (e *spanExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	l, err := e.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer e.release(ctx, l)
+	return l.exporter.ExportSpans(ctx, spans)
+}
+
+func (e *spanExporter) Shutdown(ctx context.Context) error {
+	return e.shutdown(ctx)
+}
+
+type metricExporter struct {
+	*refresher[sdkmetric.Exporter]
+}
+
+// NewMetricExporter returns a metric exporter that calls headers before every export and
+// rebuilds the underlying exporter through build whenever the headers change.
+func NewMetricExporter(ctx context.Context, static map[string]string, headers HeadersFunc, build func(context.Context, map[string]string) (sdkmetric.Exporter, error)) (sdkmetric.Exporter, error) {
+	r, err := newRefresher(ctx, static, headers, build)
+	if err != nil {
+		return nil, err
+	}
+	return &metricExporter{r}, nil
+}
+
+func (e *metricExporter) Temporality(kind sdkmetric.InstrumentKind) metricdata.Temporality {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.current.exporter.Temporality(kind)
+}
+
+func (e *metricExporter) Aggregation(kind sdkmetric.InstrumentKind) sdkmetric.Aggregation {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.current.exporter.Aggregation(kind)
+}
+
+func (e *metricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	l, err := e.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer e.release(ctx, l)
+	return l.exporter.Export(ctx, rm)
+}
+
+func (e *metricExporter) ForceFlush(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.current.exporter.ForceFlush(ctx)
+}
+
+func (e *metricExporter) Shutdown(ctx context.Context) error {
+	return e.shutdown(ctx)
+}
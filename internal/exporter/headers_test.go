@@ -0,0 +1,169 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+var errShutdown = errors.New("exporter is shut down")
+
+type fakeSpanExporter struct {
+	headers  map[string]string
+	shutdown atomic.Bool
+	started  chan struct{}
+	unblock  chan struct{}
+}
+
+func (e *fakeSpanExporter) ExportSpans(context.Context, []sdktrace.ReadOnlySpan) error {
+	if e.started != nil {
+		close(e.started)
+		<-e.unblock
+	}
+	if e.shutdown.Load() {
+		return errShutdown
+	}
+	return nil
+}
+
+func (e *fakeSpanExporter) Shutdown(context.Context) error {
+	e.shutdown.Store(true)
+	return nil
+}
+
+type fakeBuilder struct {
+	mu    sync.Mutex
+	built []*fakeSpanExporter
+	hook  func(*fakeSpanExporter)
+}
+
+func (b *fakeBuilder) build(_ context.Context, headers map[string]string) (sdktrace.SpanExporter, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	exp := &fakeSpanExporter{headers: headers}
+	if b.hook != nil {
+		b.hook(exp)
+	}
+	b.built = append(b.built, exp)
+	return exp, nil
+}
+
+func TestSpanExporterKeepsInitialExporter(t *testing.T) {
+	ctx := context.Background()
+	b := &fakeBuilder{}
+	exp, err := NewSpanExporter(ctx, map[string]string{"static": "1"}, func(context.Context) (map[string]string, error) {
+		return map[string]string{"authorization": "Bearer token"}, nil
+	}, b.build)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for range 3 {
+		if err := exp.ExportSpans(ctx, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(b.built) != 1 {
+		t.Fatalf("built %d exporters, want 1", len(b.built))
+	}
+	if got := b.built[0].headers; got["static"] != "1" || got["authorization"] != "Bearer token" {
+		t.Errorf("headers = %v", got)
+	}
+}
+
+func TestSpanExporterRebuildsOnChange(t *testing.T) {
+	ctx := context.Background()
+	b := &fakeBuilder{}
+	var token atomic.Int64
+	exp, err := NewSpanExporter(ctx, nil, func(context.Context) (map[string]string, error) {
+		return map[string]string{"authorization": fmt.Sprint(token.Load())}, nil
+	}, b.build)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token.Store(1)
+	if err := exp.ExportSpans(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(b.built) != 2 {
+		t.Fatalf("built %d exporters, want 2", len(b.built))
+	}
+	if !b.built[0].shutdown.Load() {
+		t.Error("replaced exporter was not shut down")
+	}
+	if got := b.built[1].headers["authorization"]; got != "1" {
+		t.Errorf("authorization = %q, want 1", got)
+	}
+}
+
+func TestSpanExporterFallsBackToStaticHeaders(t *testing.T) {
+	ctx := context.Background()
+	b := &fakeBuilder{}
+	var fail atomic.Bool
+	fail.Store(true)
+	exp, err := NewSpanExporter(ctx, map[string]string{"static": "1"}, func(context.Context) (map[string]string, error) {
+		if fail.Load() {
+			return nil, errors.New("token service unavailable")
+		}
+		return map[string]string{"authorization": "Bearer token"}, nil
+	}, b.build)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := exp.ExportSpans(ctx, nil); err == nil {
+		t.Error("ExportSpans succeeded without headers")
+	}
+
+	fail.Store(false)
+	if err := exp.ExportSpans(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(b.built) != 2 {
+		t.Fatalf("built %d exporters, want 2", len(b.built))
+	}
+}
+
+func TestSpanExporterConcurrentRefresh(t *testing.T) {
+	ctx := context.Background()
+	started, unblock := make(chan struct{}), make(chan struct{})
+	b := &fakeBuilder{}
+	b.hook = func(exp *fakeSpanExporter) {
+		// Block the first export on the first exporter until the headers have changed.
+		if len(b.built) == 0 {
+			exp.started, exp.unblock = started, unblock
+		}
+	}
+	var token atomic.Int64
+	exp, err := NewSpanExporter(ctx, nil, func(context.Context) (map[string]string, error) {
+		return map[string]string{"authorization": fmt.Sprint(token.Load())}, nil
+	}, b.build)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	slow := make(chan error)
+	go func() { slow <- exp.ExportSpans(ctx, nil) }()
+	<-started
+
+	token.Store(1)
+	if err := exp.ExportSpans(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if b.built[0].shutdown.Load() {
+		t.Error("exporter shut down while an export was using it")
+	}
+
+	close(unblock)
+	if err := <-slow; err != nil {
+		t.Errorf("concurrent export failed: %v", err)
+	}
+	if !b.built[0].shutdown.Load() {
+		t.Error("replaced exporter was not shut down after its last export")
+	}
+}
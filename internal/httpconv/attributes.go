@@ -0,0 +1,155 @@
+package httpconv
+
+import (
+	"net"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// Span attribute modes. Like the OpenTelemetry instrumentations, old is the default and
+// OTEL_SEMCONV_STABILITY_OPT_IN selects new with "http" and both with "http/dup"
+const (
+	// SemconvOld emits the attributes of earlier releases, e.g. http.useragent and http.target
+	SemconvOld = "old"
+	// SemconvNew emits the stable HTTP semantic conventions, e.g. user_agent.original and url.path
+	SemconvNew = "new"
+	// SemconvBoth emits both, to migrate dashboards and alerts
+	SemconvBoth = "both"
+)
+
+// ServerRequest holds the fields of a served request the span attributes are built from.
+type ServerRequest struct {
+	Method string
+	// Scheme is "http" or "https"
+	Scheme string
+	Path   string
+	// Query is the raw query, without "?"
+	Query string
+	// Route is the matched route template, e.g. "/users/{id}"
+	Route string
+	// Host is the Host header, with an optional port
+	Host      string
+	UserAgent string
+	// ClientAddress is the IP of the client, with an optional port
+	ClientAddress string
+	// ProtocolVersion is the HTTP version, e.g. "1.1" or "2"
+	ProtocolVersion string
+}
+
+// StartAttributes returns the attributes known when the request starts. Middlewares pass them
+// to tracer.Start rather than setting them afterwards, as samplers only see the attributes
+// given at start, e.g. http.route for the rules of core.RuleSampler.
+func StartAttributes(mode string, req ServerRequest) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	if mode != SemconvNew {
+		attrs = append(attrs,
+			attribute.String("http.method", req.Method),
+			attribute.String("http.route", req.Route),
+		)
+	}
+	if mode == SemconvOld {
+		return attrs
+	}
+
+	method := Method(req.Method)
+	attrs = append(attrs, semconv.HTTPRequestMethodKey.String(method))
+	if method != req.Method {
+		attrs = append(attrs, semconv.HTTPRequestMethodOriginal(req.Method))
+	}
+	attrs = append(attrs, semconv.URLPath(req.Path), semconv.URLScheme(req.Scheme))
+	if req.Query != "" {
+		attrs = append(attrs, semconv.URLQuery(req.Query))
+	}
+	if req.Route != "" && mode == SemconvNew {
+		attrs = append(attrs, semconv.HTTPRoute(req.Route))
+	}
+	if req.UserAgent != "" {
+		attrs = append(attrs, semconv.UserAgentOriginal(req.UserAgent))
+	}
+	if host, port := splitHostPort(req.Host, req.Scheme); host != "" {
+		attrs = append(attrs, semconv.ServerAddress(host))
+		if port > 0 {
+			attrs = append(attrs, semconv.ServerPort(port))
+		}
+	}
+	if client, _ := splitHostPort(req.ClientAddress, ""); client != "" {
+		attrs = append(attrs, semconv.ClientAddress(client))
+	}
+	if req.ProtocolVersion != "" {
+		attrs = append(attrs, semconv.NetworkProtocolVersion(req.ProtocolVersion))
+	}
+	return attrs
+}
+
+// EndAttributes returns the attributes known once the response is sent.
+// statusCode is 0 when no response was sent, e.g. for hijacked connections.
+func EndAttributes(mode string, req ServerRequest, statusCode int) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	if mode != SemconvNew {
+		attrs = append(attrs,
+			attribute.String("span.kind", "server"),
+			attribute.String("resource.name", req.Method+" "+req.Path),
+			attribute.String("http.method", req.Method),
+			attribute.String("http.url", req.Route),
+			attribute.String("http.raw.query", req.Query),
+			attribute.String("http.route", req.Route),
+			attribute.String("http.target", req.Route),
+			attribute.String("http.useragent", req.UserAgent),
+		)
+		if req.Host != "" {
+			attrs = append(attrs, attribute.String("http.host", req.Host))
+		}
+		if statusCode > 0 {
+			attrs = append(attrs, attribute.Int("http.status_code", statusCode))
+		}
+	}
+	if mode != SemconvOld {
+		// The route may only be known once routed, e.g. with http.ServeMux
+		if req.Route != "" {
+			attrs = append(attrs, semconv.HTTPRoute(req.Route))
+		}
+		if statusCode > 0 {
+			attrs = append(attrs, semconv.HTTPResponseStatusCode(statusCode))
+		}
+	}
+	return attrs
+}
+
+// SpanName returns the name of a server span, "{method} {route}", or the method alone when
+// the route is not known.
+func SpanName(method, route string) string {
+	if route == "" {
+		return method
+	}
+	return method + " " + route
+}
+
+// ProtocolVersion returns the network.protocol.version of a protocol such as "HTTP/1.1" or "HTTP/2.0".
+func ProtocolVersion(protocol string) string {
+	version := strings.TrimPrefix(protocol, "HTTP/")
+	if version == "2.0" || version == "3.0" {
+		return version[:1]
+	}
+	return version
+}
+
+// splitHostPort splits an address with an optional port, which defaults to the port of scheme.
+func splitHostPort(address, scheme string) (string, int) {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		// No port
+		host = strings.Trim(address, "[]")
+		switch scheme {
+		case "http":
+			return host, 80
+		case "https":
+			return host, 443
+		}
+		return host, 0
+	}
+	port, _ := strconv.Atoi(portStr)
+	return host, port
+}
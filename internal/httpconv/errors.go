@@ -0,0 +1,83 @@
+package httpconv
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime/debug"
+	"strconv"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// PanicError is the error recorded for a panic recovered from a handler.
+type PanicError struct {
+	Value any
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprint("panic: ", e.Value)
+}
+
+// Unwrap returns the panic value when it is an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// ErrorType returns the error.type of a request: "panic" for panics, the type of err, the
+// status code of 5xx responses, or "" when the request did not fail. Like 4xx responses,
+// errors answered with a status code below 500 are client errors, which do not fail the request.
+// statusCode is 0 when no status code was resolved.
+func ErrorType(statusCode int, err error) string {
+	var panicErr *PanicError
+	switch {
+	case errors.As(err, &panicErr):
+		return "panic"
+	case statusCode > 0 && statusCode < 500:
+		return ""
+	case err != nil:
+		return reflect.TypeOf(err).String()
+	case statusCode >= 500:
+		return strconv.Itoa(statusCode)
+	default:
+		return ""
+	}
+}
+
+// SetSpanError records err as an exception event, with the stack trace when stackTrace is
+// set, and marks span as failed when ErrorType reports a failure: the status is set to Error
+// along error.type. For panics it must be called before the stack unwinds, i.e. from the
+// deferred function that recovered, and the span ended before panicking again so that the
+// SDK does not record the panic a second time.
+func SetSpanError(span trace.Span, statusCode int, err error, stackTrace bool) {
+	// Panics are recorded with the type of the panic value
+	var panicErr *PanicError
+	switch {
+	case errors.As(err, &panicErr):
+		attrs := []attribute.KeyValue{
+			semconv.ExceptionType(reflect.TypeOf(panicErr.Value).String()),
+			semconv.ExceptionMessage(fmt.Sprint(panicErr.Value)),
+		}
+		if stackTrace {
+			attrs = append(attrs, semconv.ExceptionStacktrace(string(debug.Stack())))
+		}
+		span.AddEvent(semconv.ExceptionEventName, trace.WithAttributes(attrs...))
+	case err != nil:
+		span.RecordError(err, trace.WithStackTrace(stackTrace))
+	}
+
+	errorType := ErrorType(statusCode, err)
+	if errorType == "" {
+		return
+	}
+	span.SetAttributes(semconv.ErrorTypeKey.String(errorType))
+	description := ""
+	if err != nil {
+		description = err.Error()
+	}
+	span.SetStatus(codes.Error, description)
+}
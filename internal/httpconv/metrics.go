@@ -0,0 +1,123 @@
+// Package httpconv implements the OpenTelemetry semantic conventions for HTTP servers shared by
+// the middlewares.
+package httpconv
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// durationBoundaries are the bucket boundaries advised by the semantic conventions for
+// http.server.request.duration, in seconds.
+var durationBoundaries = []float64{0.005, 0.01, 0.025, 0.05, 0.075, 0.1, 0.25, 0.5, 0.75, 1, 2.5, 5, 7.5, 10}
+
+// Server records the HTTP server metrics of the middlewares, as listed in package http.
+type Server struct {
+	duration     metric.Float64Histogram
+	active       metric.Int64UpDownCounter
+	requestSize  metric.Int64Histogram
+	responseSize metric.Int64Histogram
+}
+
+// NewServer creates the HTTP server instruments with meter. Errors are reported to the
+// OpenTelemetry error handler, the instruments returned along them still work.
+func NewServer(meter metric.Meter) *Server {
+	var s Server
+	var err error
+
+	s.duration, err = meter.Float64Histogram("http.server.request.duration",
+		metric.WithDescription("Duration of HTTP server requests."),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(durationBoundaries...))
+	handle(err)
+
+	s.active, err = meter.Int64UpDownCounter("http.server.active_requests",
+		metric.WithDescription("Number of active HTTP server requests."),
+		metric.WithUnit("{request}"))
+	handle(err)
+
+	s.requestSize, err = meter.Int64Histogram("http.server.request.body.size",
+		metric.WithDescription("Size of HTTP server request bodies."),
+		metric.WithUnit("By"))
+	handle(err)
+
+	s.responseSize, err = meter.Int64Histogram("http.server.response.body.size",
+		metric.WithDescription("Size of HTTP server response bodies."),
+		metric.WithUnit("By"))
+	handle(err)
+
+	return &s
+}
+
+func handle(err error) {
+	if err != nil {
+		otel.Handle(err)
+	}
+}
+
+// Request describes a served request for the metrics recorded when it ends.
+type Request struct {
+	Method string
+	// Scheme is "http" or "https"
+	Scheme string
+	// Route is the matched route template, e.g. "/users/{id}", empty when no route matched
+	Route string
+	// StatusCode is 0 when no response was sent, e.g. for hijacked connections
+	StatusCode int
+	// ErrorType is the error.type of failed requests, see ErrorType
+	ErrorType    string
+	RequestSize  int64
+	ResponseSize int64
+	Start        time.Time
+}
+
+// Begin counts a request as active until the matching End.
+func (s *Server) Begin(ctx context.Context, method, scheme string) {
+	s.active.Add(ctx, 1, metric.WithAttributes(activeAttributes(method, scheme)...))
+}
+
+// End records the duration and sizes of a request and counts it as no longer active.
+func (s *Server) End(ctx context.Context, req Request) {
+	s.active.Add(ctx, -1, metric.WithAttributes(activeAttributes(req.Method, req.Scheme)...))
+
+	attrs := activeAttributes(req.Method, req.Scheme)
+	if req.StatusCode > 0 {
+		attrs = append(attrs, semconv.HTTPResponseStatusCode(req.StatusCode))
+	}
+	if req.Route != "" {
+		attrs = append(attrs, semconv.HTTPRoute(req.Route))
+	}
+	if req.ErrorType != "" {
+		attrs = append(attrs, semconv.ErrorTypeKey.String(req.ErrorType))
+	}
+	set := metric.WithAttributeSet(attribute.NewSet(attrs...))
+
+	s.duration.Record(ctx, time.Since(req.Start).Seconds(), set)
+	s.requestSize.Record(ctx, req.RequestSize, set)
+	s.responseSize.Record(ctx, req.ResponseSize, set)
+}
+
+func activeAttributes(method, scheme string) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		semconv.HTTPRequestMethodKey.String(Method(method)),
+		semconv.URLScheme(scheme),
+	}
+}
+
+// Method returns the method for http.request.method: the known methods as is and "_OTHER"
+// for any other, which would make the attribute unbounded.
+func Method(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	default:
+		return "_OTHER"
+	}
+}
@@ -0,0 +1,138 @@
+// Package httpmiddleware implements the tracing and metrics shared by the net/http based middlewares.
+package httpmiddleware
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/idnandre/gobsv/core"
+	"github.com/idnandre/gobsv/internal/httpconv"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Config holds what Handler traces and records requests with.
+type Config struct {
+	Tracer      trace.Tracer
+	Propagator  propagation.TextMapPropagator
+	Server      *httpconv.Server
+	SemconvMode string
+	StackTraces bool
+	// Route returns the route template of a request, or "" when it is not known. It is called
+	// before the next handler and, when empty, again after it, as routers such as
+	// http.ServeMux only set the route of the request they dispatch.
+	Route func(*http.Request) string
+}
+
+// NewConfig returns the configuration of a middleware named instrumentationName using provider.
+func NewConfig(provider *core.Provider, instrumentationName string, route func(*http.Request) string) Config {
+	return Config{
+		Tracer:      provider.Tracer(instrumentationName),
+		Propagator:  provider.Propagator(),
+		Server:      httpconv.NewServer(provider.Meter(instrumentationName)),
+		SemconvMode: string(provider.HTTPSemconv()),
+		StackTraces: provider.ErrorStackTraces(),
+		Route:       route,
+	}
+}
+
+// bodyReader counts the bytes of request bodies of unknown length.
+type bodyReader struct {
+	io.ReadCloser
+	read int64
+}
+
+func (br *bodyReader) Read(p []byte) (int, error) {
+	n, err := br.ReadCloser.Read(p)
+	br.read += int64(n)
+	return n, err
+}
+
+// Handler traces the requests served by next and records the HTTP server metrics.
+func Handler(cfg Config, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		path := cfg.Route(r)
+
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+
+		request := httpconv.ServerRequest{
+			Method:          r.Method,
+			Scheme:          scheme,
+			Path:            r.URL.Path,
+			Query:           r.URL.RawQuery,
+			Route:           path,
+			Host:            r.Host,
+			UserAgent:       r.UserAgent(),
+			ClientAddress:   r.RemoteAddr,
+			ProtocolVersion: httpconv.ProtocolVersion(r.Proto),
+		}
+
+		ctx := cfg.Propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := cfg.Tracer.Start(ctx, httpconv.SpanName(r.Method, path),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(httpconv.StartAttributes(cfg.SemconvMode, request)...),
+		)
+
+		cfg.Server.Begin(ctx, r.Method, scheme)
+
+		newRequest := r.WithContext(ctx)
+		var body *bodyReader
+		if r.ContentLength < 0 {
+			body = &bodyReader{ReadCloser: r.Body}
+			newRequest.Body = body
+		}
+		newResponseWriter := newResponseWriter(w)
+
+		// Requests are recorded and the span ended when the handler returns or panics,
+		// the panic is then recorded as error and propagated
+		defer func() {
+			var err error
+			recovered := recover()
+			statusCode := newResponseWriter.status()
+			if recovered != nil {
+				err = &httpconv.PanicError{Value: recovered}
+				if !newResponseWriter.wroteHeader {
+					// net/http aborts the response, which clients see as a server error
+					statusCode = http.StatusInternalServerError
+				}
+			}
+
+			if path == "" {
+				if path = cfg.Route(newRequest); path != "" {
+					request.Route = path
+					span.SetName(httpconv.SpanName(r.Method, path))
+				}
+			}
+
+			requestSize := r.ContentLength
+			if body != nil {
+				requestSize = body.read
+			}
+			cfg.Server.End(ctx, httpconv.Request{
+				Method:       r.Method,
+				Scheme:       scheme,
+				Route:        path,
+				StatusCode:   statusCode,
+				ErrorType:    httpconv.ErrorType(statusCode, err),
+				RequestSize:  requestSize,
+				ResponseSize: newResponseWriter.written,
+				Start:        start,
+			})
+
+			span.SetAttributes(httpconv.EndAttributes(cfg.SemconvMode, request, statusCode)...)
+			httpconv.SetSpanError(span, statusCode, err, cfg.StackTraces)
+			span.End()
+
+			if recovered != nil {
+				panic(recovered)
+			}
+		}()
+
+		next.ServeHTTP(wrapResponseWriter(newResponseWriter), newRequest)
+	})
+}
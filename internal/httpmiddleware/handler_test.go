@@ -0,0 +1,115 @@
+package httpmiddleware_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/idnandre/gobsv/internal/httpmiddleware"
+	"github.com/idnandre/gobsv/internal/middlewaretest"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestHandlerMetrics(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		request func() *http.Request
+		route   func(*http.Request) string
+		handler http.HandlerFunc
+		want    middlewaretest.ServerRequest
+	}{
+		{
+			name: "body of unknown length",
+			request: func() *http.Request {
+				r := httptest.NewRequest(http.MethodPut, "/files/a", io.NopCloser(strings.NewReader("content")))
+				r.ContentLength = -1
+				return r
+			},
+			route: func(*http.Request) string { return "/files/{name}" },
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				io.Copy(io.Discard, r.Body)
+				w.WriteHeader(http.StatusNoContent)
+			},
+			want: middlewaretest.ServerRequest{
+				Attributes: []attribute.KeyValue{
+					attribute.String("http.request.method", http.MethodPut),
+					attribute.String("url.scheme", "http"),
+					attribute.Int("http.response.status_code", http.StatusNoContent),
+					attribute.String("http.route", "/files/{name}"),
+				},
+				RequestSize: int64(len("content")),
+			},
+		},
+		{
+			name:    "server error",
+			request: func() *http.Request { return httptest.NewRequest("PURGE", "https://example.com/cache", nil) },
+			route:   func(*http.Request) string { return "" },
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			},
+			want: middlewaretest.ServerRequest{
+				Attributes: []attribute.KeyValue{
+					attribute.String("http.request.method", "_OTHER"),
+					attribute.String("url.scheme", "https"),
+					attribute.Int("http.response.status_code", http.StatusServiceUnavailable),
+					attribute.String("error.type", "503"),
+				},
+				ResponseSize: int64(len("unavailable\n")),
+			},
+		},
+		{
+			name:    "route known after the handler",
+			request: func() *http.Request { return httptest.NewRequest(http.MethodGet, "/late", nil) },
+			route: func(r *http.Request) string {
+				if r.Header.Get("X-Routed") == "" {
+					return ""
+				}
+				return "/late"
+			},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				r.Header.Set("X-Routed", "1")
+			},
+			want: middlewaretest.ServerRequest{
+				Attributes: []attribute.KeyValue{
+					attribute.String("http.request.method", http.MethodGet),
+					attribute.String("url.scheme", "http"),
+					attribute.Int("http.response.status_code", http.StatusOK),
+					attribute.String("http.route", "/late"),
+				},
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := middlewaretest.NewRecorder(t)
+			httpmiddleware.Handler(rec.Config(tt.route), tt.handler).ServeHTTP(httptest.NewRecorder(), tt.request())
+			middlewaretest.CheckServerMetrics(t, rec.Metrics(t), tt.want)
+		})
+	}
+}
+
+func TestHandlerPanicMetrics(t *testing.T) {
+	rec := middlewaretest.NewRecorder(t)
+	handler := httpmiddleware.Handler(rec.Config(func(*http.Request) string { return "/panic" }),
+		http.HandlerFunc(func(http.ResponseWriter, *http.Request) { panic("boom") }))
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("the panic was not propagated")
+			}
+		}()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/panic", nil))
+	}()
+
+	middlewaretest.CheckServerMetrics(t, rec.Metrics(t), middlewaretest.ServerRequest{
+		Attributes: []attribute.KeyValue{
+			attribute.String("http.request.method", http.MethodGet),
+			attribute.String("url.scheme", "http"),
+			attribute.Int("http.response.status_code", http.StatusInternalServerError),
+			attribute.String("http.route", "/panic"),
+			attribute.String("error.type", "panic"),
+		},
+	})
+}
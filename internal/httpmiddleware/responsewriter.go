@@ -0,0 +1,212 @@
+package httpmiddleware
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+)
+
+// responseWriter records the status code and body size of a response. It is wrapped by
+// wrapResponseWriter to keep the optional interfaces of the underlying writer.
+type responseWriter struct {
+	http.ResponseWriter
+	statusCode  int
+	written     int64
+	wroteHeader bool
+	hijacked    bool
+}
+
+func newResponseWriter(w http.ResponseWriter) *responseWriter {
+	return &responseWriter{ResponseWriter: w}
+}
+
+func (rw *responseWriter) WriteHeader(code int) {
+	// Informational responses, except 101 Switching Protocols, are followed by the final one
+	if !rw.wroteHeader && (code < 100 || code > 199 || code == http.StatusSwitchingProtocols) {
+		rw.statusCode = code
+		rw.wroteHeader = true
+	}
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	rw.writeHeader()
+	n, err := rw.ResponseWriter.Write(b)
+	rw.written += int64(n)
+	return n, err
+}
+
+// Unwrap returns the underlying writer, for http.ResponseController.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// writeHeader records the implicit 200 sent by the first write or flush.
+func (rw *responseWriter) writeHeader() {
+	if !rw.wroteHeader {
+		rw.statusCode = http.StatusOK
+		rw.wroteHeader = true
+	}
+}
+
+// status returns the status code sent, or the one net/http sends once the handler returns
+// without writing. It is 0 for hijacked connections, whose response is unknown.
+func (rw *responseWriter) status() int {
+	if rw.wroteHeader {
+		return rw.statusCode
+	}
+	if rw.hijacked {
+		return 0
+	}
+	return http.StatusOK
+}
+
+type flusher struct{ rw *responseWriter }
+
+func (f flusher) Flush() {
+	f.rw.writeHeader()
+	f.rw.ResponseWriter.(http.Flusher).Flush()
+}
+
+type hijacker struct{ rw *responseWriter }
+
+func (h hijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, buf, err := h.rw.ResponseWriter.(http.Hijacker).Hijack()
+	if err == nil {
+		h.rw.hijacked = true
+	}
+	return conn, buf, err
+}
+
+type readerFrom struct{ rw *responseWriter }
+
+func (r readerFrom) ReadFrom(src io.Reader) (int64, error) {
+	r.rw.writeHeader()
+	n, err := r.rw.ResponseWriter.(io.ReaderFrom).ReadFrom(src)
+	r.rw.written += n
+	return n, err
+}
+
+type pusher struct{ rw *responseWriter }
+
+func (p pusher) Push(target string, opts *http.PushOptions) error {
+	return p.rw.ResponseWriter.(http.Pusher).Push(target, opts)
+}
+
+// wrapResponseWriter returns rw as a writer implementing the same optional interfaces among
+// http.Flusher, http.Hijacker, io.ReaderFrom and http.Pusher as the writer it wraps, so that
+// handlers can keep detecting them with type assertions.
+func wrapResponseWriter(rw *responseWriter) http.ResponseWriter {
+	var interfaces int
+	if _, ok := rw.ResponseWriter.(http.Flusher); ok {
+		interfaces |= 1
+	}
+	if _, ok := rw.ResponseWriter.(http.Hijacker); ok {
+		interfaces |= 2
+	}
+	if _, ok := rw.ResponseWriter.(io.ReaderFrom); ok {
+		interfaces |= 4
+	}
+	if _, ok := rw.ResponseWriter.(http.Pusher); ok {
+		interfaces |= 8
+	}
+
+	f, h, r, p := flusher{rw}, hijacker{rw}, readerFrom{rw}, pusher{rw}
+	switch interfaces {
+	case 1:
+		return struct {
+			*responseWriter
+			flusher
+		}{rw, f}
+	case 2:
+		return struct {
+			*responseWriter
+			hijacker
+		}{rw, h}
+	case 3:
+		return struct {
+			*responseWriter
+			flusher
+			hijacker
+		}{rw, f, h}
+	case 4:
+		return struct {
+			*responseWriter
+			readerFrom
+		}{rw, r}
+	case 5:
+		return struct {
+			*responseWriter
+			flusher
+			readerFrom
+		}{rw, f, r}
+	case 6:
+		return struct {
+			*responseWriter
+			hijacker
+			readerFrom
+		}{rw, h, r}
+	case 7:
+		return struct {
+			*responseWriter
+			flusher
+			hijacker
+			readerFrom
+		}{rw, f, h, r}
+	case 8:
+		return struct {
+			*responseWriter
+			pusher
+		}{rw, p}
+	case 9:
+		return struct {
+			*responseWriter
+			flusher
+			pusher
+		}{rw, f, p}
+	case 10:
+		return struct {
+			*responseWriter
+			hijacker
+			pusher
+		}{rw, h, p}
+	case 11:
+		return struct {
+			*responseWriter
+			flusher
+			hijacker
+			pusher
+		}{rw, f, h, p}
+	case 12:
+		return struct {
+			*responseWriter
+			readerFrom
+			pusher
+		}{rw, r, p}
+	case 13:
+		return struct {
+			*responseWriter
+			flusher
+			readerFrom
+			pusher
+		}{rw, f, r, p}
+	case 14:
+		return struct {
+			*responseWriter
+			hijacker
+			readerFrom
+			pusher
+		}{rw, h, r, p}
+	case 15:
+		return struct {
+			*responseWriter
+			flusher
+			hijacker
+			readerFrom
+			pusher
+		}{rw, f, h, r, p}
+	default:
+		return rw
+	}
+}
@@ -0,0 +1,311 @@
+package httpmiddleware
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeWriter implements every optional interface and records what reached it.
+type fakeWriter struct {
+	*httptest.ResponseRecorder
+	flushes  int
+	hijacked bool
+	readFrom int64
+	pushed   []string
+}
+
+func (w *fakeWriter) Flush() {
+	w.flushes++
+}
+
+func (w *fakeWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.hijacked = true
+	return nil, nil, nil
+}
+
+func (w *fakeWriter) ReadFrom(src io.Reader) (int64, error) {
+	n, err := io.Copy(w.ResponseRecorder, src)
+	w.readFrom += n
+	return n, err
+}
+
+func (w *fakeWriter) Push(target string, _ *http.PushOptions) error {
+	w.pushed = append(w.pushed, target)
+	return nil
+}
+
+const (
+	withFlusher = 1 << iota
+	withHijacker
+	withReaderFrom
+	withPusher
+)
+
+// newFakeWriter returns a writer implementing the optional interfaces of mask only.
+func newFakeWriter(mask int) (http.ResponseWriter, *fakeWriter) {
+	f := &fakeWriter{ResponseRecorder: httptest.NewRecorder()}
+	type rw = http.ResponseWriter
+	switch mask {
+	case 0:
+		return struct{ rw }{f}, f
+	case withFlusher:
+		return struct {
+			rw
+			http.Flusher
+		}{f, f}, f
+	case withHijacker:
+		return struct {
+			rw
+			http.Hijacker
+		}{f, f}, f
+	case withFlusher | withHijacker:
+		return struct {
+			rw
+			http.Flusher
+			http.Hijacker
+		}{f, f, f}, f
+	case withReaderFrom:
+		return struct {
+			rw
+			io.ReaderFrom
+		}{f, f}, f
+	case withFlusher | withReaderFrom:
+		return struct {
+			rw
+			http.Flusher
+			io.ReaderFrom
+		}{f, f, f}, f
+	case withHijacker | withReaderFrom:
+		return struct {
+			rw
+			http.Hijacker
+			io.ReaderFrom
+		}{f, f, f}, f
+	case withFlusher | withHijacker | withReaderFrom:
+		return struct {
+			rw
+			http.Flusher
+			http.Hijacker
+			io.ReaderFrom
+		}{f, f, f, f}, f
+	case withPusher:
+		return struct {
+			rw
+			http.Pusher
+		}{f, f}, f
+	case withFlusher | withPusher:
+		return struct {
+			rw
+			http.Flusher
+			http.Pusher
+		}{f, f, f}, f
+	case withHijacker | withPusher:
+		return struct {
+			rw
+			http.Hijacker
+			http.Pusher
+		}{f, f, f}, f
+	case withFlusher | withHijacker | withPusher:
+		return struct {
+			rw
+			http.Flusher
+			http.Hijacker
+			http.Pusher
+		}{f, f, f, f}, f
+	case withReaderFrom | withPusher:
+		return struct {
+			rw
+			io.ReaderFrom
+			http.Pusher
+		}{f, f, f}, f
+	case withFlusher | withReaderFrom | withPusher:
+		return struct {
+			rw
+			http.Flusher
+			io.ReaderFrom
+			http.Pusher
+		}{f, f, f, f}, f
+	case withHijacker | withReaderFrom | withPusher:
+		return struct {
+			rw
+			http.Hijacker
+			io.ReaderFrom
+			http.Pusher
+		}{f, f, f, f}, f
+	default:
+		return struct {
+			rw
+			http.Flusher
+			http.Hijacker
+			io.ReaderFrom
+			http.Pusher
+		}{f, f, f, f, f}, f
+	}
+}
+
+func maskName(mask int) string {
+	var names []string
+	for _, bit := range []struct {
+		mask int
+		name string
+	}{
+		{withFlusher, "Flusher"},
+		{withHijacker, "Hijacker"},
+		{withReaderFrom, "ReaderFrom"},
+		{withPusher, "Pusher"},
+	} {
+		if mask&bit.mask != 0 {
+			names = append(names, bit.name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, "+")
+}
+
+func TestWrapResponseWriter(t *testing.T) {
+	for mask := range 16 {
+		t.Run(maskName(mask), func(t *testing.T) {
+			newWriter := func() (http.ResponseWriter, *responseWriter, *fakeWriter) {
+				underlying, fake := newFakeWriter(mask)
+				rw := newResponseWriter(underlying)
+				return wrapResponseWriter(rw), rw, fake
+			}
+
+			t.Run("interfaces", func(t *testing.T) {
+				w, _, _ := newWriter()
+				_, isFlusher := w.(http.Flusher)
+				_, isHijacker := w.(http.Hijacker)
+				_, isReaderFrom := w.(io.ReaderFrom)
+				_, isPusher := w.(http.Pusher)
+				for name, tt := range map[string]struct{ got, want bool }{
+					"http.Flusher":  {isFlusher, mask&withFlusher != 0},
+					"http.Hijacker": {isHijacker, mask&withHijacker != 0},
+					"io.ReaderFrom": {isReaderFrom, mask&withReaderFrom != 0},
+					"http.Pusher":   {isPusher, mask&withPusher != 0},
+				} {
+					if tt.got != tt.want {
+						t.Errorf("implements %s = %v, want %v", name, tt.got, tt.want)
+					}
+				}
+			})
+
+			t.Run("implicit 200", func(t *testing.T) {
+				w, rw, fake := newWriter()
+				if got := rw.status(); got != http.StatusOK {
+					t.Errorf("status before writing = %d, want 200", got)
+				}
+				w.Write([]byte("hello"))
+				if got := rw.status(); got != http.StatusOK {
+					t.Errorf("status = %d, want 200", got)
+				}
+				if rw.written != 5 || fake.Body.Len() != 5 {
+					t.Errorf("written = %d, body = %d bytes, want 5", rw.written, fake.Body.Len())
+				}
+			})
+
+			t.Run("informational", func(t *testing.T) {
+				w, rw, _ := newWriter()
+				w.WriteHeader(http.StatusEarlyHints)
+				w.WriteHeader(http.StatusNoContent)
+				if got := rw.status(); got != http.StatusNoContent {
+					t.Errorf("status = %d, want 204", got)
+				}
+			})
+
+			t.Run("switching protocols", func(t *testing.T) {
+				w, rw, _ := newWriter()
+				w.WriteHeader(http.StatusSwitchingProtocols)
+				w.WriteHeader(http.StatusOK)
+				if got := rw.status(); got != http.StatusSwitchingProtocols {
+					t.Errorf("status = %d, want 101", got)
+				}
+			})
+
+			t.Run("hijacked", func(t *testing.T) {
+				w, rw, fake := newWriter()
+				_, _, err := http.NewResponseController(w).Hijack()
+				if mask&withHijacker == 0 {
+					if !errors.Is(err, http.ErrNotSupported) {
+						t.Errorf("Hijack() error = %v, want http.ErrNotSupported", err)
+					}
+					return
+				}
+				if err != nil || !fake.hijacked {
+					t.Fatalf("Hijack() error = %v, reached writer = %v", err, fake.hijacked)
+				}
+				if got := rw.status(); got != 0 {
+					t.Errorf("status = %d, want 0", got)
+				}
+			})
+
+			t.Run("ReadFrom", func(t *testing.T) {
+				w, rw, fake := newWriter()
+				readerFrom, ok := w.(io.ReaderFrom)
+				if !ok {
+					return
+				}
+				w.Write([]byte("hello"))
+				readerFrom.ReadFrom(strings.NewReader("abc"))
+				if rw.written != 8 || fake.readFrom != 3 {
+					t.Errorf("written = %d, read by writer = %d, want 8 and 3", rw.written, fake.readFrom)
+				}
+				if got := rw.status(); got != http.StatusOK {
+					t.Errorf("status = %d, want 200", got)
+				}
+			})
+
+			t.Run("Push", func(t *testing.T) {
+				w, _, fake := newWriter()
+				if pusher, ok := w.(http.Pusher); ok {
+					pusher.Push("/style.css", nil)
+					if len(fake.pushed) != 1 {
+						t.Errorf("pushed = %v, want [/style.css]", fake.pushed)
+					}
+				}
+			})
+
+			t.Run("ResponseController", func(t *testing.T) {
+				w, rw, fake := newWriter()
+				err := http.NewResponseController(w).Flush()
+				if mask&withFlusher == 0 {
+					if !errors.Is(err, http.ErrNotSupported) {
+						t.Errorf("Flush() error = %v, want http.ErrNotSupported", err)
+					}
+					return
+				}
+				if err != nil || fake.flushes != 1 {
+					t.Fatalf("Flush() error = %v, flushes = %d, want 1", err, fake.flushes)
+				}
+				// Flushing sends the header with the implicit 200
+				w.WriteHeader(http.StatusNotFound)
+				if got := rw.status(); got != http.StatusOK {
+					t.Errorf("status = %d, want 200", got)
+				}
+			})
+		})
+	}
+}
+
+func TestResponseControllerUnwrap(t *testing.T) {
+	// responseWriter itself has no Flush, the controller reaches the recorder through Unwrap
+	recorder := httptest.NewRecorder()
+	if err := http.NewResponseController(newResponseWriter(recorder)).Flush(); err != nil {
+		t.Errorf("Flush() error = %v", err)
+	}
+	if !recorder.Flushed {
+		t.Error("Flush() did not reach the recorder")
+	}
+
+	hidden := struct{ http.ResponseWriter }{httptest.NewRecorder()}
+	if err := http.NewResponseController(newResponseWriter(hidden)).Flush(); !errors.Is(err, http.ErrNotSupported) {
+		t.Errorf("Flush() error = %v, want http.ErrNotSupported", err)
+	}
+}
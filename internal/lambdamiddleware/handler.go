@@ -0,0 +1,64 @@
+// Package lambdamiddleware implements the tracing shared by the API Gateway middlewares.
+package lambdamiddleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/idnandre/gobsv/core"
+	"github.com/idnandre/gobsv/internal/httpconv"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Config holds what Invoke traces invocations with.
+type Config struct {
+	Tracer      trace.Tracer
+	Propagator  propagation.TextMapPropagator
+	SemconvMode string
+	StackTraces bool
+}
+
+// NewConfig returns the configuration of a middleware named instrumentationName using provider.
+func NewConfig(provider *core.Provider, instrumentationName string) Config {
+	return Config{
+		Tracer:      provider.Tracer(instrumentationName),
+		Propagator:  provider.Propagator(),
+		SemconvMode: string(provider.HTTPSemconv()),
+		StackTraces: provider.ErrorStackTraces(),
+	}
+}
+
+// Invoke traces the invocation of handler for an API Gateway event described by request,
+// continuing the trace context found in headers. Returned errors, panics and 5xx responses
+// set the span status to Error; panics are propagated once recorded.
+func Invoke[E any](ctx context.Context, cfg Config, headers propagation.TextMapCarrier, request httpconv.ServerRequest, event E, handler func(context.Context, E) (events.APIGatewayProxyResponse, error)) (events.APIGatewayProxyResponse, error) {
+	ctx = cfg.Propagator.Extract(ctx, headers)
+	ctx, span := cfg.Tracer.Start(ctx, request.Method+" "+request.Route,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(append(httpconv.StartAttributes(cfg.SemconvMode, request), semconv.FaaSTriggerHTTP)...),
+	)
+	// A panic is recorded as error and propagated
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			httpconv.SetSpanError(span, 0, &httpconv.PanicError{Value: recovered}, cfg.StackTraces)
+			span.End()
+			panic(recovered)
+		}
+	}()
+
+	response, err := handler(ctx, event)
+
+	span.SetAttributes(httpconv.EndAttributes(cfg.SemconvMode, request, response.StatusCode)...)
+	// API Gateway responds with 502 to errors whatever the response, they are recorded along 5xx responses
+	errorStatusCode := response.StatusCode
+	if err != nil {
+		errorStatusCode = http.StatusBadGateway
+	}
+	httpconv.SetSpanError(span, errorStatusCode, err, cfg.StackTraces)
+	span.End()
+
+	return response, err
+}
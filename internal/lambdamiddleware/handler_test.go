@@ -0,0 +1,89 @@
+package lambdamiddleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/idnandre/gobsv/internal/httpconv"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func newConfig() (Config, *tracetest.SpanRecorder) {
+	recorder := tracetest.NewSpanRecorder()
+	return Config{
+		Tracer:      sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test"),
+		Propagator:  propagation.TraceContext{},
+		SemconvMode: httpconv.SemconvNew,
+	}, recorder
+}
+
+func invoke(cfg Config, handler func(context.Context, string) (events.APIGatewayProxyResponse, error)) (events.APIGatewayProxyResponse, error) {
+	request := httpconv.ServerRequest{Method: http.MethodGet, Route: "/users/{id}", Path: "/users/1"}
+	return Invoke(context.Background(), cfg, propagation.HeaderCarrier{}, request, "event", handler)
+}
+
+func TestInvoke(t *testing.T) {
+	errHandler := errors.New("handler failed")
+	for _, tt := range []struct {
+		name       string
+		statusCode int
+		err        error
+		want       codes.Code
+	}{
+		{"ok", http.StatusOK, nil, codes.Unset},
+		{"client error", http.StatusNotFound, nil, codes.Unset},
+		{"server error", http.StatusServiceUnavailable, nil, codes.Error},
+		{"returned error", 0, errHandler, codes.Error},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, recorder := newConfig()
+			_, err := invoke(cfg, func(context.Context, string) (events.APIGatewayProxyResponse, error) {
+				return events.APIGatewayProxyResponse{StatusCode: tt.statusCode}, tt.err
+			})
+			if !errors.Is(err, tt.err) {
+				t.Errorf("error = %v, want %v", err, tt.err)
+			}
+
+			spans := recorder.Ended()
+			if len(spans) != 1 {
+				t.Fatalf("got %d spans, want 1", len(spans))
+			}
+			if spans[0].Name() != "GET /users/{id}" {
+				t.Errorf("name = %q, want GET /users/{id}", spans[0].Name())
+			}
+			if got := spans[0].Status().Code; got != tt.want {
+				t.Errorf("status = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInvokePanic(t *testing.T) {
+	cfg, recorder := newConfig()
+	defer func() {
+		if recovered := recover(); recovered != "boom" {
+			t.Errorf("recovered %v, want the handler panic", recovered)
+		}
+
+		spans := recorder.Ended()
+		if len(spans) != 1 {
+			t.Fatalf("got %d spans, want 1", len(spans))
+		}
+		if got := spans[0].Status().Code; got != codes.Error {
+			t.Errorf("status = %v, want Error", got)
+		}
+		if events := spans[0].Events(); len(events) != 1 {
+			t.Errorf("got %d events, want the panic recorded once", len(events))
+		}
+	}()
+
+	invoke(cfg, func(context.Context, string) (events.APIGatewayProxyResponse, error) {
+		panic("boom")
+	})
+}
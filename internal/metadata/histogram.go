@@ -0,0 +1,72 @@
+package metadata
+
+import (
+	"math"
+	metrics "runtime/metrics"
+	"sort"
+	"strings"
+)
+
+var (
+	// secondsBounds covers scheduler latencies and GC pauses, from 1µs to 10s.
+	secondsBounds = []float64{
+		0.000001, 0.000005, 0.00001, 0.00005, 0.0001, 0.0005,
+		0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10,
+	}
+	// bytesBounds covers the allocation size classes, from 8B to 32KiB.
+	bytesBounds = []float64{
+		8, 16, 32, 64, 128, 256, 512, 1024, 2048, 4096, 8192, 16384, 32768,
+	}
+)
+
+// Function to get the explicit bucket boundaries a runtime histogram is exported with.
+// The runtime histograms have far more buckets than useful for export, so they are folded
+// into fixed boundaries depending on their unit.
+func HistogramBounds(metric metrics.Description, h *metrics.Float64Histogram) []float64 {
+	switch {
+	case strings.HasSuffix(metric.Name, ":seconds"):
+		return secondsBounds
+	case strings.HasSuffix(metric.Name, ":bytes"):
+		return bytesBounds
+	}
+
+	// Unknown unit, keep the finite boundaries of the runtime
+	bounds := make([]float64, 0, len(h.Buckets))
+	for _, b := range h.Buckets {
+		if !math.IsInf(b, 0) {
+			bounds = append(bounds, b)
+		}
+	}
+	return bounds
+}
+
+// Function to fold a runtime histogram into explicit buckets as used by OpenTelemetry:
+// counts[i] receives the values in (bounds[i-1], bounds[i]] and the last of the
+// len(bounds)+1 counts the values above the last bound. A runtime bucket is attributed by
+// its upper boundary, so values are never reported lower than they were.
+// It returns the total count and the sum estimated from the bucket midpoints, since
+// runtime histograms carry no sum.
+func FoldHistogram(h *metrics.Float64Histogram, bounds []float64, counts []uint64) (uint64, float64) {
+	clear(counts)
+
+	var total uint64
+	var sum float64
+	for i, count := range h.Counts {
+		if count == 0 {
+			continue
+		}
+		lo, hi := h.Buckets[i], h.Buckets[i+1]
+		counts[sort.SearchFloat64s(bounds, hi)] += count
+		total += count
+
+		switch {
+		case math.IsInf(lo, -1):
+			sum += hi * float64(count)
+		case math.IsInf(hi, 1):
+			sum += lo * float64(count)
+		default:
+			sum += (lo + hi) / 2 * float64(count)
+		}
+	}
+	return total, sum
+}
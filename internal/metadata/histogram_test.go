@@ -0,0 +1,79 @@
+package metadata
+
+import (
+	"math"
+	"runtime/metrics"
+	"slices"
+	"testing"
+)
+
+func TestHistogramBounds(t *testing.T) {
+	runtime := &metrics.Float64Histogram{
+		Counts:  []uint64{0, 0, 0},
+		Buckets: []float64{math.Inf(-1), 1, 2, math.Inf(1)},
+	}
+	for _, tt := range []struct {
+		name string
+		want []float64
+	}{
+		{"/sched/latencies:seconds", secondsBounds},
+		{"/gc/heap/allocs-by-size:bytes", bytesBounds},
+		// Unknown units keep the runtime boundaries without the infinite edges
+		{"/custom/distribution:objects", []float64{1, 2}},
+	} {
+		if got := HistogramBounds(metrics.Description{Name: tt.name}, runtime); !slices.Equal(got, tt.want) {
+			t.Errorf("HistogramBounds(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFoldHistogram(t *testing.T) {
+	bounds := []float64{1, 2, 5}
+	for _, tt := range []struct {
+		name      string
+		histogram metrics.Float64Histogram
+		counts    []uint64
+		total     uint64
+		sum       float64
+	}{
+		{
+			name:      "empty",
+			histogram: metrics.Float64Histogram{Counts: []uint64{0, 0}, Buckets: []float64{math.Inf(-1), 1, math.Inf(1)}},
+			counts:    []uint64{0, 0, 0, 0},
+		},
+		{
+			// A bucket goes by its upper boundary, those ending on a bound stay below it
+			name: "finite buckets",
+			histogram: metrics.Float64Histogram{
+				Counts:  []uint64{2, 3, 4, 5},
+				Buckets: []float64{0.5, 1, 1.5, 3, 10},
+			},
+			counts: []uint64{2, 3, 4, 5},
+			total:  14,
+			sum:    0.75*2 + 1.25*3 + 2.25*4 + 6.5*5,
+		},
+		{
+			// Infinite edges have no midpoint, their finite boundary is summed instead
+			name: "infinite edges",
+			histogram: metrics.Float64Histogram{
+				Counts:  []uint64{1, 2, 6},
+				Buckets: []float64{math.Inf(-1), 0.5, 10, math.Inf(1)},
+			},
+			counts: []uint64{1, 0, 0, 8},
+			total:  9,
+			sum:    0.5*1 + 5.25*2 + 10*6,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			// Counts are overwritten, not added to
+			counts := []uint64{7, 7, 7, 7}
+			total, sum := FoldHistogram(&tt.histogram, bounds, counts)
+			if !slices.Equal(counts, tt.counts) {
+				t.Errorf("counts = %v, want %v", counts, tt.counts)
+			}
+			if total != tt.total || sum != tt.sum {
+				t.Errorf("FoldHistogram() = %d, %v, want %d, %v", total, sum, tt.total, tt.sum)
+			}
+		})
+	}
+}
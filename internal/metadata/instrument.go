@@ -0,0 +1,75 @@
+package metadata
+
+import (
+	metrics "runtime/metrics"
+	"strings"
+)
+
+// Instrument kinds runtime metrics are exported as
+type InstrumentKind int
+
+const (
+	KindCounter InstrumentKind = iota
+	KindUpDownCounter
+	KindGauge
+	KindHistogram
+)
+
+// Instrument describes the OpenTelemetry instrument a runtime metric is exported as
+type Instrument struct {
+	Kind InstrumentKind
+	// Int is set for Int64 instruments, otherwise Float64 is used
+	Int  bool
+	Unit string
+}
+
+// Units of non cumulative metrics whose values add up, e.g. the memory classes summing to
+// /memory/classes/total:bytes, they are exported as up-down counters instead of gauges
+var additiveUnits = map[string]bool{
+	"bytes":      true,
+	"objects":    true,
+	"goroutines": true,
+	"threads":    true,
+}
+
+// Function to get the instrument of a runtime metric:
+// cumulative metrics are counters, additive amounts such as bytes or goroutines up-down counters
+// and the remaining values, e.g. percentages or GC cycle numbers, gauges. Uint64 values use
+// Int64 instruments so counts are not rounded above 2^53.
+func GetInstrument(metric metrics.Description) Instrument {
+	instrument := Instrument{
+		Int:  metric.Kind == metrics.KindUint64,
+		Unit: GetUnit(metric),
+	}
+
+	_, unit, _ := strings.Cut(metric.Name, ":")
+	switch {
+	case metric.Kind == metrics.KindFloat64Histogram:
+		instrument.Kind = KindHistogram
+	case metric.Cumulative:
+		instrument.Kind = KindCounter
+	case additiveUnits[unit]:
+		instrument.Kind = KindUpDownCounter
+	default:
+		instrument.Kind = KindGauge
+	}
+	return instrument
+}
+
+// Function to get the UCUM unit of a runtime metric from its name suffix
+func GetUnit(metric metrics.Description) string {
+	_, unit, _ := strings.Cut(metric.Name, ":")
+	switch unit {
+	case "bytes":
+		return "By"
+	case "seconds", "cpu-seconds":
+		return "s"
+	case "percent":
+		return "%"
+	case "":
+		return ""
+	default:
+		// Annotation units are singular, e.g. {goroutine}
+		return "{" + strings.TrimSuffix(strings.ReplaceAll(unit, "-", "_"), "s") + "}"
+	}
+}
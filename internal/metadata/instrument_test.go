@@ -0,0 +1,91 @@
+package metadata
+
+import (
+	"runtime/metrics"
+	"strings"
+	"testing"
+)
+
+func TestGetInstrument(t *testing.T) {
+	for name, want := range map[string]Instrument{
+		"/cpu/classes/gc/mark/assist:cpu-seconds": {Kind: KindCounter, Unit: "s"},
+		"/gc/cycles/automatic:gc-cycles":          {Kind: KindCounter, Int: true, Unit: "{gc_cycle}"},
+		"/gc/heap/allocs:bytes":                   {Kind: KindCounter, Int: true, Unit: "By"},
+		"/gc/heap/objects:objects":                {Kind: KindUpDownCounter, Int: true, Unit: "{object}"},
+		"/memory/classes/total:bytes":             {Kind: KindUpDownCounter, Int: true, Unit: "By"},
+		"/sched/goroutines:goroutines":            {Kind: KindUpDownCounter, Int: true, Unit: "{goroutine}"},
+		"/sched/gomaxprocs:threads":               {Kind: KindUpDownCounter, Int: true, Unit: "{thread}"},
+		"/gc/gogc:percent":                        {Kind: KindGauge, Int: true, Unit: "%"},
+		"/gc/limiter/last-enabled:gc-cycle":       {Kind: KindGauge, Int: true, Unit: "{gc_cycle}"},
+		"/sched/latencies:seconds":                {Kind: KindHistogram, Unit: "s"},
+	} {
+		metric, ok := description(name)
+		if !ok {
+			t.Errorf("%s not supported by the runtime", name)
+			continue
+		}
+		if got := GetInstrument(metric); got != want {
+			t.Errorf("GetInstrument(%s) = %+v, want %+v", name, got, want)
+		}
+	}
+}
+
+func TestGetInstrumentAll(t *testing.T) {
+	units := map[string]string{
+		"bytes":       "By",
+		"calls":       "{call}",
+		"cleanups":    "{cleanup}",
+		"cpu-seconds": "s",
+		"events":      "{event}",
+		"finalizers":  "{finalizer}",
+		"gc-cycle":    "{gc_cycle}",
+		"gc-cycles":   "{gc_cycle}",
+		"goroutines":  "{goroutine}",
+		"objects":     "{object}",
+		"percent":     "%",
+		"seconds":     "s",
+		"threads":     "{thread}",
+	}
+	for _, metric := range metrics.All() {
+		_, unit, _ := strings.Cut(metric.Name, ":")
+		instrument := GetInstrument(metric)
+
+		if want, ok := units[unit]; !ok {
+			t.Errorf("%s: unit %q has no expected UCUM unit, got %q", metric.Name, unit, GetUnit(metric))
+		} else if got := GetUnit(metric); got != want || instrument.Unit != want {
+			t.Errorf("%s: unit = %q, instrument unit = %q, want %q", metric.Name, got, instrument.Unit, want)
+		}
+
+		if instrument.Int != (metric.Kind == metrics.KindUint64) {
+			t.Errorf("%s: Int = %v for kind %v", metric.Name, instrument.Int, metric.Kind)
+		}
+		switch {
+		case metric.Kind == metrics.KindFloat64Histogram:
+			if instrument.Kind != KindHistogram {
+				t.Errorf("%s: kind = %v, want histogram", metric.Name, instrument.Kind)
+			}
+		case metric.Cumulative:
+			if instrument.Kind != KindCounter {
+				t.Errorf("%s: kind = %v, want counter for a cumulative metric", metric.Name, instrument.Kind)
+			}
+		case instrument.Kind == KindCounter || instrument.Kind == KindHistogram:
+			t.Errorf("%s: kind = %v for a non cumulative metric", metric.Name, instrument.Kind)
+		}
+	}
+}
+
+func TestGetUnitWithoutSuffix(t *testing.T) {
+	if got := GetUnit(metrics.Description{Name: "/custom/metric"}); got != "" {
+		t.Errorf("GetUnit() = %q, want no unit", got)
+	}
+}
+
+// description returns the runtime description of the metric name.
+func description(name string) (metrics.Description, bool) {
+	for _, metric := range metrics.All() {
+		if metric.Name == name {
+			return metric, true
+		}
+	}
+	return metrics.Description{}, false
+}
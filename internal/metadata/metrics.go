@@ -1,59 +1,22 @@
 package metadata
 
 import (
-	"fmt"
 	metrics "runtime/metrics"
 	"strings"
 )
 
-const (
-	errorValue = -1.0
-)
-
-// Function to get metrics values from runtime/metrics package
-func GetAllMetrics() []metrics.Sample {
-	metricsMetadata := metrics.All()
-	samples := make([]metrics.Sample, len(metricsMetadata))
-	// update name of each sample
-	for idx := range metricsMetadata {
-		samples[idx].Name = metricsMetadata[idx].Name
-	}
-	metrics.Read(samples)
-	return samples
-}
-
-// Function to get metrics values from runtime/metrics package as float64
-func GetSingleMetricFloat(metricName string) float64 {
-
-	// Create a sample for the metric.
-	sample := make([]metrics.Sample, 1)
-	sample[0].Name = metricName
-
-	// Sample the metric.
-	metrics.Read(sample)
-
-	return getFloat64(sample[0])
-}
-
-// function to return differemt sample values as float 64
-// curently it handles single values, in future it will handle histograms
-func getFloat64(sample metrics.Sample) float64 {
-	var floatVal float64
-	// Handle each sample.
+// Function to get the value of an already read sample as float64
+// it handles single values, histograms are handled by FoldHistogram. Samples of other kinds,
+// including metrics unknown to this Go version, read as 0.
+func GetSampleFloat(sample metrics.Sample) float64 {
 	switch sample.Value.Kind() {
 	case metrics.KindUint64:
-		floatVal = float64(sample.Value.Uint64())
+		return float64(sample.Value.Uint64())
 	case metrics.KindFloat64:
-		floatVal = float64(sample.Value.Float64())
-	case metrics.KindFloat64Histogram:
-		// TODO: implementation needed
-		return errorValue
-	case metrics.KindBad:
-		panic("bug in runtime/metrics package!")
+		return sample.Value.Float64()
 	default:
-		panic(fmt.Sprintf("%s: unexpected metric Kind: %v\n", sample.Name, sample.Value.Kind()))
+		return 0
 	}
-	return floatVal
 }
 
 // Function to get metrics subsysyetm from a mteric metadata
@@ -0,0 +1,90 @@
+package metadata
+
+import (
+	metrics "runtime/metrics"
+)
+
+// Runtime metric presets
+const (
+	PresetNone    = "none"
+	PresetMinimal = "minimal"
+	PresetDefault = "default"
+	PresetAll     = "all"
+)
+
+var presets = map[string][]string{
+	PresetNone: nil,
+	// heap, goroutines, GC cycles and GC pauses
+	PresetMinimal: {
+		"/memory/classes/heap/objects:bytes",
+		"/memory/classes/total:bytes",
+		"/gc/heap/goal:bytes",
+		"/sched/goroutines:goroutines",
+		"/gc/cycles/total:gc-cycles",
+		"/gc/pauses:seconds",
+		"/sched/pauses/total/gc:seconds",
+	},
+	PresetDefault: {"*"},
+	PresetAll:     {"*"},
+}
+
+// presetExcludes are removed from a preset before the user supplied patterns apply
+var presetExcludes = map[string][]string{
+	// one counter per GODEBUG setting, rarely looked at
+	PresetDefault: {"/godebug/*"},
+}
+
+// Function to check whether a runtime metric preset exists
+func IsPreset(preset string) bool {
+	_, ok := presets[preset]
+	return ok
+}
+
+// Function to select the runtime metrics to export: the metrics of the preset plus the ones
+// matching include, minus the ones matching exclude. Patterns are exact names or globs
+// where "*" matches any sequence of characters, including "/", and "?" any single character.
+func Select(all []metrics.Description, preset string, include, exclude []string) []metrics.Description {
+	selected := make([]metrics.Description, 0, len(all))
+	for _, metric := range all {
+		inPreset := matchAny(presets[preset], metric.Name) && !matchAny(presetExcludes[preset], metric.Name)
+		if (inPreset || matchAny(include, metric.Name)) && !matchAny(exclude, metric.Name) {
+			selected = append(selected, metric)
+		}
+	}
+	return selected
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matchGlob(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob reports whether name matches pattern, backtracking on the last "*" seen
+func matchGlob(pattern, name string) bool {
+	p, n := 0, 0
+	star, next := -1, 0
+	for n < len(name) {
+		switch {
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == name[n]):
+			p++
+			n++
+		case p < len(pattern) && pattern[p] == '*':
+			star, next = p, n
+			p++
+		case star >= 0:
+			p = star + 1
+			next++
+			n = next
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
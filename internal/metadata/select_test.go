@@ -0,0 +1,133 @@
+package metadata
+
+import (
+	"runtime/metrics"
+	"slices"
+	"testing"
+)
+
+func TestMatchGlob(t *testing.T) {
+	for _, tt := range []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"/gc/cycles/total:gc-cycles", "/gc/cycles/total:gc-cycles", true},
+		{"/gc/cycles/total:gc-cycles", "/gc/cycles/total:gc-cycle", false},
+		{"*", "/sched/goroutines:goroutines", true},
+		{"*", "", true},
+		// "*" crosses "/"
+		{"/gc/*", "/gc/heap/allocs:bytes", true},
+		{"/memory/*:bytes", "/memory/classes/heap/objects:bytes", true},
+		{"/memory/*:bytes", "/memory/classes/heap/objects:objects", false},
+		{"*:seconds", "/sched/pauses/total/gc:seconds", true},
+		// backtracking on the last "*"
+		{"/*/heap/*:bytes", "/gc/heap/heap/goal:bytes", true},
+		{"/gc/*/goal:bytes", "/gc/heap/goal:bytes", true},
+		{"/gc/*/goal:bytes", "/gc/goal:bytes", false},
+		// "?" matches exactly one character, "/" included
+		{"/gc/gogc:percen?", "/gc/gogc:percent", true},
+		{"/gc?gogc:percent", "/gc/gogc:percent", true},
+		{"/gc/gogc:percent?", "/gc/gogc:percent", false},
+		{"/gc/heap/goal:bytes", "/gc/heap/goal:bytes/extra", false},
+	} {
+		if got := matchGlob(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSelect(t *testing.T) {
+	all := []metrics.Description{
+		{Name: "/gc/cycles/total:gc-cycles"},
+		{Name: "/gc/heap/allocs:bytes"},
+		{Name: "/godebug/non-default-behavior/http2client:events"},
+		{Name: "/sched/goroutines:goroutines"},
+		{Name: "/sched/latencies:seconds"},
+	}
+	for _, tt := range []struct {
+		name             string
+		preset           string
+		include, exclude []string
+		want             []string
+	}{
+		{
+			name:   "none",
+			preset: PresetNone,
+		},
+		{
+			name:   "unknown preset",
+			preset: "everything",
+		},
+		{
+			name:   "minimal",
+			preset: PresetMinimal,
+			want:   []string{"/gc/cycles/total:gc-cycles", "/sched/goroutines:goroutines"},
+		},
+		{
+			// The GODEBUG counters are left out by default
+			name:   "default",
+			preset: PresetDefault,
+			want:   []string{"/gc/cycles/total:gc-cycles", "/gc/heap/allocs:bytes", "/sched/goroutines:goroutines", "/sched/latencies:seconds"},
+		},
+		{
+			name:   "all",
+			preset: PresetAll,
+			want:   []string{"/gc/cycles/total:gc-cycles", "/gc/heap/allocs:bytes", "/godebug/non-default-behavior/http2client:events", "/sched/goroutines:goroutines", "/sched/latencies:seconds"},
+		},
+		{
+			name:    "include adds to the preset",
+			preset:  PresetMinimal,
+			include: []string{"*:seconds"},
+			want:    []string{"/gc/cycles/total:gc-cycles", "/sched/goroutines:goroutines", "/sched/latencies:seconds"},
+		},
+		{
+			name:    "include overrides the default exclusion",
+			preset:  PresetDefault,
+			include: []string{"/godebug/*"},
+			want:    []string{"/gc/cycles/total:gc-cycles", "/gc/heap/allocs:bytes", "/godebug/non-default-behavior/http2client:events", "/sched/goroutines:goroutines", "/sched/latencies:seconds"},
+		},
+		{
+			name:    "exclude removes from the preset",
+			preset:  PresetAll,
+			exclude: []string{"/gc/*", "/godebug/*"},
+			want:    []string{"/sched/goroutines:goroutines", "/sched/latencies:seconds"},
+		},
+		{
+			name:    "exclude wins over include",
+			preset:  PresetNone,
+			include: []string{"/sched/*"},
+			exclude: []string{"/sched/latencies:seconds"},
+			want:    []string{"/sched/goroutines:goroutines"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, metric := range Select(all, tt.preset, tt.include, tt.exclude) {
+				got = append(got, metric.Name)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Select() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPresetsExist(t *testing.T) {
+	supported := make(map[string]bool)
+	for _, metric := range metrics.All() {
+		supported[metric.Name] = true
+	}
+	for preset, patterns := range presets {
+		if !IsPreset(preset) {
+			t.Errorf("IsPreset(%s) = false", preset)
+		}
+		for _, pattern := range patterns {
+			if pattern != "*" && !supported[pattern] {
+				t.Errorf("preset %s selects %s, which the runtime does not support", preset, pattern)
+			}
+		}
+	}
+	if IsPreset("everything") {
+		t.Error("IsPreset(everything) = true")
+	}
+}
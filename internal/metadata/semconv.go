@@ -0,0 +1,165 @@
+package metadata
+
+import (
+	"math"
+	metrics "runtime/metrics"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Runtime metric naming schemes
+const (
+	// NamingSemconv follows the OpenTelemetry semantic conventions for Go runtime metrics
+	NamingSemconv = "semconv"
+	// NamingLegacy derives names like gc_heap_allocs_bytes from the runtime/metrics names
+	NamingLegacy = "legacy"
+)
+
+// SemconvMetric is a Go runtime metric of the semantic conventions, computed from runtime/metrics samples
+type SemconvMetric struct {
+	Name        string
+	Unit        string
+	Description string
+	Kind        InstrumentKind
+	// Source is the runtime metric it replaces, it is exported when Source is selected
+	Source string
+	// Points are empty for histograms, which are taken from Source as is
+	Points []SemconvPoint
+}
+
+// SemconvPoint is a data point computed as the sum of the Add samples minus the Sub samples
+type SemconvPoint struct {
+	Attributes attribute.Set
+	Add        []string
+	Sub        []string
+	// Unset, when not 0, is the value the runtime reports when nothing is configured, for
+	// which no data point is recorded
+	Unset int64
+}
+
+const goMemoryTypeKey = attribute.Key("go.memory.type")
+
+var semconvMetrics = []SemconvMetric{
+	{
+		Name:        "go.memory.used",
+		Unit:        "By",
+		Description: "Memory used by the Go runtime.",
+		Kind:        KindUpDownCounter,
+		Source:      "/memory/classes/total:bytes",
+		Points: []SemconvPoint{
+			{
+				Attributes: attribute.NewSet(goMemoryTypeKey.String("stack")),
+				Add:        []string{"/memory/classes/heap/stacks:bytes", "/memory/classes/os-stacks:bytes"},
+			},
+			{
+				Attributes: attribute.NewSet(goMemoryTypeKey.String("other")),
+				Add:        []string{"/memory/classes/total:bytes"},
+				Sub:        []string{"/memory/classes/heap/released:bytes", "/memory/classes/heap/stacks:bytes", "/memory/classes/os-stacks:bytes"},
+			},
+		},
+	},
+	{
+		Name:        "go.memory.limit",
+		Unit:        "By",
+		Description: "Go runtime memory limit configured by the user, if a limit exists.",
+		Kind:        KindUpDownCounter,
+		Source:      "/gc/gomemlimit:bytes",
+		// The limit is math.MaxInt64 without GOMEMLIMIT or debug.SetMemoryLimit
+		Points: []SemconvPoint{{Add: []string{"/gc/gomemlimit:bytes"}, Unset: math.MaxInt64}},
+	},
+	{
+		Name:        "go.memory.allocated",
+		Unit:        "By",
+		Description: "Memory allocated to the heap by the application.",
+		Kind:        KindCounter,
+		Source:      "/gc/heap/allocs:bytes",
+		Points:      []SemconvPoint{{Add: []string{"/gc/heap/allocs:bytes"}}},
+	},
+	{
+		Name:        "go.memory.allocations",
+		Unit:        "{allocation}",
+		Description: "Count of allocations to the heap by the application.",
+		Kind:        KindCounter,
+		Source:      "/gc/heap/allocs:objects",
+		Points:      []SemconvPoint{{Add: []string{"/gc/heap/allocs:objects"}}},
+	},
+	{
+		Name:        "go.memory.gc.goal",
+		Unit:        "By",
+		Description: "Heap size target for the end of the GC cycle.",
+		Kind:        KindUpDownCounter,
+		Source:      "/gc/heap/goal:bytes",
+		Points:      []SemconvPoint{{Add: []string{"/gc/heap/goal:bytes"}}},
+	},
+	{
+		Name:        "go.goroutine.count",
+		Unit:        "{goroutine}",
+		Description: "Count of live goroutines.",
+		Kind:        KindUpDownCounter,
+		Source:      "/sched/goroutines:goroutines",
+		Points:      []SemconvPoint{{Add: []string{"/sched/goroutines:goroutines"}}},
+	},
+	{
+		Name:        "go.processor.limit",
+		Unit:        "{thread}",
+		Description: "The number of OS threads that can execute user-level Go code simultaneously.",
+		Kind:        KindUpDownCounter,
+		Source:      "/sched/gomaxprocs:threads",
+		Points:      []SemconvPoint{{Add: []string{"/sched/gomaxprocs:threads"}}},
+	},
+	{
+		Name:        "go.config.gogc",
+		Unit:        "%",
+		Description: "Heap size target percentage configured by the user, otherwise 100.",
+		Kind:        KindUpDownCounter,
+		Source:      "/gc/gogc:percent",
+		Points:      []SemconvPoint{{Add: []string{"/gc/gogc:percent"}}},
+	},
+	{
+		Name:        "go.schedule.duration",
+		Unit:        "s",
+		Description: "The time goroutines have spent in the scheduler in a runnable state before actually running.",
+		Kind:        KindHistogram,
+		Source:      "/sched/latencies:seconds",
+	},
+}
+
+// Function to get the semantic convention metric replacing a runtime metric, if any
+func GetSemconvMetric(name string) (SemconvMetric, bool) {
+	for _, metric := range semconvMetrics {
+		if metric.Source == name {
+			return metric, true
+		}
+	}
+	return SemconvMetric{}, false
+}
+
+// multiUnitPaths holds the runtime metric paths that exist in several units, such as
+// /gc/heap/frees in bytes and in objects.
+var multiUnitPaths = func() map[string]bool {
+	units := make(map[string]int)
+	for _, metric := range metrics.All() {
+		path, _, _ := strings.Cut(metric.Name, ":")
+		units[path]++
+	}
+	paths := make(map[string]bool)
+	for path, n := range units {
+		if n > 1 {
+			paths[path] = true
+		}
+	}
+	return paths
+}()
+
+// Function to derive a name in the go.* namespace for runtime metrics without semantic
+// convention, e.g. /gc/cycles/total:gc-cycles becomes go.gc.cycles.total. Paths that exist
+// in several units keep the unit, e.g. go.gc.heap.frees.bytes and go.gc.heap.frees.objects.
+func GetSemconvName(metric metrics.Description) string {
+	path, unit, _ := strings.Cut(metric.Name, ":")
+	name := strings.ReplaceAll(strings.Trim(path, "/"), "/", ".")
+	if multiUnitPaths[path] && unit != "" {
+		name += "." + unit
+	}
+	return "go." + strings.ReplaceAll(name, "-", "_")
+}
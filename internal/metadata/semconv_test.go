@@ -0,0 +1,34 @@
+package metadata
+
+import (
+	"runtime/metrics"
+	"testing"
+)
+
+func TestSemconvNamesUnique(t *testing.T) {
+	seen := make(map[string]string)
+	for _, metric := range metrics.All() {
+		name := GetSemconvName(metric)
+		if semconvMetric, ok := GetSemconvMetric(metric.Name); ok {
+			name = semconvMetric.Name
+		}
+		if other, ok := seen[name]; ok {
+			t.Errorf("%s and %s are both exported as %s", other, metric.Name, name)
+		}
+		seen[name] = metric.Name
+	}
+}
+
+func TestGetSemconvName(t *testing.T) {
+	for name, want := range map[string]string{
+		"/gc/cycles/total:gc-cycles":              "go.gc.cycles.total",
+		"/gc/heap/frees:bytes":                    "go.gc.heap.frees.bytes",
+		"/gc/heap/frees:objects":                  "go.gc.heap.frees.objects",
+		"/cpu/classes/gc/mark/assist:cpu-seconds": "go.cpu.classes.gc.mark.assist",
+		"/sched/goroutines:goroutines":            "go.sched.goroutines",
+	} {
+		if got := GetSemconvName(metrics.Description{Name: name}); got != want {
+			t.Errorf("GetSemconvName(%s) = %s, want %s", name, got, want)
+		}
+	}
+}
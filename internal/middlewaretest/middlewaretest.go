@@ -0,0 +1,157 @@
+// Package middlewaretest records the spans and metrics of the HTTP middlewares in memory.
+package middlewaretest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/idnandre/gobsv/internal/httpconv"
+	"github.com/idnandre/gobsv/internal/httpmiddleware"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// Recorder keeps the spans and metrics recorded through it.
+type Recorder struct {
+	Spans  *tracetest.SpanRecorder
+	Reader *sdkmetric.ManualReader
+
+	tracerProvider *sdktrace.TracerProvider
+	meterProvider  *sdkmetric.MeterProvider
+}
+
+// NewRecorder returns a recorder whose providers are shut down when the test ends.
+func NewRecorder(t testing.TB) *Recorder {
+	t.Helper()
+	r := &Recorder{
+		Spans:  tracetest.NewSpanRecorder(),
+		Reader: sdkmetric.NewManualReader(),
+	}
+	r.tracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(r.Spans))
+	r.meterProvider = sdkmetric.NewMeterProvider(sdkmetric.WithReader(r.Reader))
+	t.Cleanup(func() {
+		r.tracerProvider.Shutdown(context.Background())
+		r.meterProvider.Shutdown(context.Background())
+	})
+	return r
+}
+
+// Config returns the middleware configuration recording to r with the new HTTP semantic
+// conventions.
+func (r *Recorder) Config(route func(*http.Request) string) httpmiddleware.Config {
+	return httpmiddleware.Config{
+		Tracer:      r.tracerProvider.Tracer("middlewaretest"),
+		Propagator:  propagation.TraceContext{},
+		Server:      httpconv.NewServer(r.meterProvider.Meter("middlewaretest")),
+		SemconvMode: httpconv.SemconvNew,
+		Route:       route,
+	}
+}
+
+// SpanNames returns the names of the ended spans.
+func (r *Recorder) SpanNames() []string {
+	var names []string
+	for _, span := range r.Spans.Ended() {
+		names = append(names, span.Name())
+	}
+	return names
+}
+
+// Metrics collects the metrics recorded so far by name.
+func (r *Recorder) Metrics(t testing.TB) map[string]metricdata.Metrics {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := r.Reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	metrics := make(map[string]metricdata.Metrics)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			metrics[m.Name] = m
+		}
+	}
+	return metrics
+}
+
+// ServerRequest is the request the HTTP server metrics are expected for.
+type ServerRequest struct {
+	// Attributes are those of the duration and body sizes
+	Attributes   []attribute.KeyValue
+	RequestSize  int64
+	ResponseSize int64
+}
+
+// CheckServerMetrics checks the names, units and attributes of the HTTP server metrics
+// recorded for a single request.
+func CheckServerMetrics(t testing.TB, metrics map[string]metricdata.Metrics, want ServerRequest) {
+	t.Helper()
+	attrs := attribute.NewSet(want.Attributes...)
+
+	duration := metric[metricdata.Histogram[float64]](t, metrics, "http.server.request.duration", "s")
+	if points := duration.DataPoints; len(points) != 1 || points[0].Count != 1 || !points[0].Attributes.Equals(&attrs) {
+		t.Errorf("http.server.request.duration = %v, want one request with %v", describe(points), encode(attrs))
+	}
+
+	for name, size := range map[string]int64{
+		"http.server.request.body.size":  want.RequestSize,
+		"http.server.response.body.size": want.ResponseSize,
+	} {
+		sizes := metric[metricdata.Histogram[int64]](t, metrics, name, "By")
+		if points := sizes.DataPoints; len(points) != 1 || points[0].Sum != size || !points[0].Attributes.Equals(&attrs) {
+			t.Errorf("%s = %v, want %d bytes with %v", name, describe(points), size, encode(attrs))
+		}
+	}
+
+	// Active requests only carry the method and scheme, and are back to 0
+	var activeAttrs []attribute.KeyValue
+	for _, kv := range want.Attributes {
+		if kv.Key == "http.request.method" || kv.Key == "url.scheme" {
+			activeAttrs = append(activeAttrs, kv)
+		}
+	}
+	activeSet := attribute.NewSet(activeAttrs...)
+	active := metric[metricdata.Sum[int64]](t, metrics, "http.server.active_requests", "{request}")
+	if points := active.DataPoints; len(points) != 1 || points[0].Value != 0 || !points[0].Attributes.Equals(&activeSet) {
+		var got []string
+		for _, point := range points {
+			got = append(got, fmt.Sprintf("%d with %s", point.Value, encode(point.Attributes)))
+		}
+		t.Errorf("http.server.active_requests = %v, want 0 with %v", got, encode(activeSet))
+	}
+}
+
+// metric returns the data of the metric name after checking its unit.
+func metric[T metricdata.Aggregation](t testing.TB, metrics map[string]metricdata.Metrics, name, unit string) T {
+	t.Helper()
+	m, ok := metrics[name]
+	if !ok {
+		t.Fatalf("%s not recorded", name)
+	}
+	if m.Unit != unit {
+		t.Errorf("%s unit = %q, want %q", name, m.Unit, unit)
+	}
+	data, ok := m.Data.(T)
+	if !ok {
+		t.Fatalf("%s data = %T, want %T", name, m.Data, data)
+	}
+	return data
+}
+
+// describe returns the count, sum and attributes of histogram data points.
+func describe[N int64 | float64](points []metricdata.HistogramDataPoint[N]) []string {
+	var descriptions []string
+	for _, point := range points {
+		descriptions = append(descriptions, fmt.Sprintf("%d totalling %v with %s", point.Count, point.Sum, encode(point.Attributes)))
+	}
+	return descriptions
+}
+
+func encode(set attribute.Set) string {
+	return set.Encoded(attribute.DefaultEncoder())
+}
@@ -0,0 +1,245 @@
+// Package otlptest provides in-process OTLP/gRPC and OTLP/HTTP collectors for the tests of the
+// packages that export telemetry.
+package otlptest
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	collectormetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	collectortracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/stats"
+)
+
+// Collector is an OTLP/gRPC receiver that keeps everything it is sent.
+type Collector struct {
+	// Addr is the "host:port" the collector listens on.
+	Addr string
+
+	mu          sync.Mutex
+	spans       []*tracepb.ResourceSpans
+	metrics     []*metricpb.ResourceMetrics
+	headers     []metadata.MD
+	compression []string
+}
+
+// NewCollector starts a collector on a random local port and stops it when the test ends.
+func NewCollector(t testing.TB, opts ...grpc.ServerOption) *Collector {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	c := &Collector{Addr: lis.Addr().String()}
+	srv := grpc.NewServer(append(opts, grpc.StatsHandler(compressionRecorder{c}))...)
+	collectortracepb.RegisterTraceServiceServer(srv, traceService{c: c})
+	collectormetricpb.RegisterMetricsServiceServer(srv, metricsService{c: c})
+
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	return c
+}
+
+type traceService struct {
+	collectortracepb.UnimplementedTraceServiceServer
+	c *Collector
+}
+
+func (s traceService) Export(ctx context.Context, req *collectortracepb.ExportTraceServiceRequest) (*collectortracepb.ExportTraceServiceResponse, error) {
+	s.c.mu.Lock()
+	defer s.c.mu.Unlock()
+	s.c.spans = append(s.c.spans, req.GetResourceSpans()...)
+	s.c.recordHeaders(ctx)
+	return &collectortracepb.ExportTraceServiceResponse{}, nil
+}
+
+type metricsService struct {
+	collectormetricpb.UnimplementedMetricsServiceServer
+	c *Collector
+}
+
+func (s metricsService) Export(ctx context.Context, req *collectormetricpb.ExportMetricsServiceRequest) (*collectormetricpb.ExportMetricsServiceResponse, error) {
+	s.c.mu.Lock()
+	defer s.c.mu.Unlock()
+	s.c.metrics = append(s.c.metrics, req.GetResourceMetrics()...)
+	s.c.recordHeaders(ctx)
+	return &collectormetricpb.ExportMetricsServiceResponse{}, nil
+}
+
+func (c *Collector) recordHeaders(ctx context.Context) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	c.headers = append(c.headers, md)
+}
+
+// Spans returns the resource spans received so far.
+func (c *Collector) Spans() []*tracepb.ResourceSpans {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*tracepb.ResourceSpans{}, c.spans...)
+}
+
+// SpanNames returns the names of all spans received so far.
+func (c *Collector) SpanNames() []string {
+	var names []string
+	for _, span := range c.SpanList() {
+		names = append(names, span.GetName())
+	}
+	return names
+}
+
+// Metrics returns the resource metrics received so far.
+func (c *Collector) Metrics() []*metricpb.ResourceMetrics {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*metricpb.ResourceMetrics{}, c.metrics...)
+}
+
+// Headers returns the metadata of every export request received so far.
+func (c *Collector) Headers() []metadata.MD {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]metadata.MD{}, c.headers...)
+}
+
+// Compression returns the grpc-encoding of every request received so far, empty when uncompressed.
+func (c *Collector) Compression() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string{}, c.compression...)
+}
+
+// compressionRecorder records the compression of incoming requests, which gRPC removes
+// from the metadata passed to the handlers.
+type compressionRecorder struct{ c *Collector }
+
+func (compressionRecorder) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (r compressionRecorder) HandleRPC(_ context.Context, s stats.RPCStats) {
+	if in, ok := s.(*stats.InHeader); ok {
+		r.c.mu.Lock()
+		r.c.compression = append(r.c.compression, in.Compression)
+		r.c.mu.Unlock()
+	}
+}
+
+func (compressionRecorder) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (compressionRecorder) HandleConn(context.Context, stats.ConnStats) {}
+
+// Certificate returns a self-signed certificate together with its PEM encoding, to serve
+// TLS, to authenticate clients and to verify both. It is valid for localhost and 127.0.0.1,
+// or for dnsNames alone when given.
+func Certificate(t testing.TB, dnsNames ...string) (tls.Certificate, []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	if len(dnsNames) > 0 {
+		template.Subject.CommonName = dnsNames[0]
+		template.DNSNames = dnsNames
+		template.IPAddresses = nil
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key},
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+// WriteKeyPair writes the PEM encoded certificate and key of cert, as returned by
+// Certificate, to a temporary directory and returns their paths.
+func WriteKeyPair(t testing.TB, cert tls.Certificate) (certFile, keyFile string) {
+	t.Helper()
+
+	key, err := x509.MarshalECPrivateKey(cert.PrivateKey.(*ecdsa.PrivateKey))
+	if err != nil {
+		t.Fatalf("failed to encode key: %v", err)
+	}
+	dir := t.TempDir()
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: key}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+// ResourceAttributes returns the resource attributes of the first spans received, nil when
+// none were received.
+func (c *Collector) ResourceAttributes() map[string]string {
+	spans := c.Spans()
+	if len(spans) == 0 {
+		return nil
+	}
+	return Attributes(spans[0].GetResource().GetAttributes())
+}
+
+// SpanList returns all spans received so far.
+func (c *Collector) SpanList() []*tracepb.Span {
+	var list []*tracepb.Span
+	for _, rs := range c.Spans() {
+		for _, ss := range rs.GetScopeSpans() {
+			list = append(list, ss.GetSpans()...)
+		}
+	}
+	return list
+}
+
+// Attributes returns the string form of the string, int and bool attributes.
+func Attributes(kvs []*commonpb.KeyValue) map[string]string {
+	attrs := make(map[string]string, len(kvs))
+	for _, kv := range kvs {
+		switch value := kv.GetValue().GetValue().(type) {
+		case *commonpb.AnyValue_StringValue:
+			attrs[kv.GetKey()] = value.StringValue
+		case *commonpb.AnyValue_IntValue:
+			attrs[kv.GetKey()] = strconv.FormatInt(value.IntValue, 10)
+		case *commonpb.AnyValue_BoolValue:
+			attrs[kv.GetKey()] = strconv.FormatBool(value.BoolValue)
+		}
+	}
+	return attrs
+}
@@ -0,0 +1,136 @@
+package otlptest
+
+import (
+	"compress/gzip"
+	"crypto/tls"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	collectormetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	collectortracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// HTTPCollector is an OTLP/HTTP receiver that keeps everything it is sent.
+type HTTPCollector struct {
+	// Addr is the "host:port" the collector listens on.
+	Addr string
+
+	mu       sync.Mutex
+	requests []HTTPRequest
+	spans    []*tracepb.ResourceSpans
+	metrics  []*metricpb.ResourceMetrics
+}
+
+// HTTPRequest describes an export request received by an HTTPCollector.
+type HTTPRequest struct {
+	Path   string
+	Header http.Header
+	// TLS is the connection state of TLS requests, nil otherwise.
+	TLS *tls.ConnectionState
+}
+
+// NewHTTPCollector starts a collector on a random local port and stops it when the test ends.
+// It serves TLS when tlsConfig is set.
+func NewHTTPCollector(t testing.TB, tlsConfig *tls.Config) *HTTPCollector {
+	t.Helper()
+
+	c := &HTTPCollector{}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(c.serveHTTP))
+	// Failed handshakes are what TLS tests expect, they are not logged
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	if tlsConfig != nil {
+		srv.TLS = tlsConfig
+		srv.StartTLS()
+	} else {
+		srv.Start()
+	}
+	t.Cleanup(srv.Close)
+
+	c.Addr = srv.Listener.Addr().String()
+	return c
+}
+
+func (c *HTTPCollector) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body := io.Reader(r.Body)
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body = gz
+	}
+	b, err := io.ReadAll(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests = append(c.requests, HTTPRequest{Path: r.URL.Path, Header: r.Header.Clone(), TLS: r.TLS})
+
+	var resp proto.Message
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/v1/traces"):
+		var req collectortracepb.ExportTraceServiceRequest
+		if err := proto.Unmarshal(b, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		c.spans = append(c.spans, req.GetResourceSpans()...)
+		resp = &collectortracepb.ExportTraceServiceResponse{}
+	case strings.HasSuffix(r.URL.Path, "/v1/metrics"):
+		var req collectormetricpb.ExportMetricsServiceRequest
+		if err := proto.Unmarshal(b, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		c.metrics = append(c.metrics, req.GetResourceMetrics()...)
+		resp = &collectormetricpb.ExportMetricsServiceResponse{}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	out, _ := proto.Marshal(resp)
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.Write(out)
+}
+
+// Requests returns the export requests received so far.
+func (c *HTTPCollector) Requests() []HTTPRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]HTTPRequest{}, c.requests...)
+}
+
+// SpanNames returns the names of all spans received so far.
+func (c *HTTPCollector) SpanNames() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var names []string
+	for _, rs := range c.spans {
+		for _, ss := range rs.GetScopeSpans() {
+			for _, span := range ss.GetSpans() {
+				names = append(names, span.GetName())
+			}
+		}
+	}
+	return names
+}
+
+// Metrics returns the resource metrics received so far.
+func (c *HTTPCollector) Metrics() []*metricpb.ResourceMetrics {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*metricpb.ResourceMetrics{}, c.metrics...)
+}
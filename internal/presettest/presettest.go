@@ -0,0 +1,153 @@
+// Package presettest is the test suite shared by the presets built on the core package,
+// together with the helpers of the tests that export to an otlptest collector.
+package presettest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/idnandre/gobsv/core"
+	"github.com/idnandre/gobsv/internal/otlptest"
+)
+
+// New is the New function of a preset.
+type New func(context.Context, ...core.Option) (*core.Provider, error)
+
+// Options returns the options that point a pipeline at the collector.
+func Options(c *otlptest.Collector, opts ...core.Option) []core.Option {
+	return append([]core.Option{
+		core.WithProtocol(core.ProtocolGRPC),
+		core.WithEndpoint(c.Addr),
+		core.WithServiceName("preset-test"),
+		core.WithRuntimeMetrics(core.RuntimeMetricsMinimal),
+		core.WithGlobal(false),
+	}, opts...)
+}
+
+// NewProvider returns a provider exporting to a new collector with Options, shut down when
+// the test ends.
+func NewProvider(t testing.TB, opts ...core.Option) (*core.Provider, *otlptest.Collector) {
+	t.Helper()
+	c := otlptest.NewCollector(t)
+	p, err := core.New(context.Background(), Options(c, opts...)...)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	t.Cleanup(func() { p.Shutdown(context.Background()) })
+	return p, c
+}
+
+// SpanNames flushes p and returns the names of all spans c received.
+func SpanNames(t testing.TB, p *core.Provider, c *otlptest.Collector) []string {
+	t.Helper()
+	flush(t, p)
+	return c.SpanNames()
+}
+
+// Spans flushes p and returns the attributes of all spans c received, with the span name
+// under "name".
+func Spans(t testing.TB, p *core.Provider, c *otlptest.Collector) []map[string]string {
+	t.Helper()
+	flush(t, p)
+	var spans []map[string]string
+	for _, span := range c.SpanList() {
+		attrs := otlptest.Attributes(span.GetAttributes())
+		attrs["name"] = span.GetName()
+		spans = append(spans, attrs)
+	}
+	return spans
+}
+
+func flush(t testing.TB, p *core.Provider) {
+	t.Helper()
+	if err := p.ForceFlush(context.Background()); err != nil {
+		t.Fatalf("ForceFlush() error = %v", err)
+	}
+}
+
+// Run runs the suite against the preset created by newProvider.
+func Run(t *testing.T, newProvider New) {
+	t.Run("New", func(t *testing.T) {
+		c := otlptest.NewCollector(t)
+		p, err := newProvider(context.Background(), Options(c)...)
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		defer p.Shutdown(context.Background())
+
+		if p.TracerProvider() == nil || p.MeterProvider() == nil || p.Propagator() == nil {
+			t.Error("New() returned a provider without pipelines")
+		}
+	})
+
+	t.Run("invalid configuration", func(t *testing.T) {
+		p, err := newProvider(context.Background(), core.WithProtocol("udp"), core.WithGlobal(false))
+		if err == nil {
+			p.Shutdown(context.Background())
+			t.Fatal("New() accepted an unsupported protocol")
+		}
+		if p != nil {
+			t.Error("New() returned a provider together with an error")
+		}
+	})
+
+	t.Run("ForceFlush", func(t *testing.T) {
+		ctx := context.Background()
+		c := otlptest.NewCollector(t)
+		p, err := newProvider(ctx, Options(c)...)
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		defer p.Shutdown(ctx)
+
+		_, span := p.Tracer("presettest").Start(ctx, "flushed")
+		span.End()
+		if err := p.ForceFlush(ctx); err != nil {
+			t.Fatalf("ForceFlush() error = %v", err)
+		}
+
+		if names := c.SpanNames(); len(names) != 1 || names[0] != "flushed" {
+			t.Errorf("spans = %v, want [flushed]", names)
+		}
+		if len(c.Metrics()) == 0 {
+			t.Error("no metrics received")
+		}
+		if got := c.ResourceAttributes()["service.name"]; got != "preset-test" {
+			t.Errorf("service.name = %q, want preset-test", got)
+		}
+	})
+
+	t.Run("Shutdown twice", func(t *testing.T) {
+		ctx := context.Background()
+		c := otlptest.NewCollector(t)
+		p, err := newProvider(ctx, Options(c)...)
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+
+		_, span := p.Tracer("presettest").Start(ctx, "shutdown")
+		span.End()
+		if err := p.Shutdown(ctx); err != nil {
+			t.Fatalf("first Shutdown() error = %v", err)
+		}
+		if err := p.Shutdown(ctx); err != nil {
+			t.Errorf("second Shutdown() error = %v", err)
+		}
+		if names := c.SpanNames(); len(names) != 1 || names[0] != "shutdown" {
+			t.Errorf("spans = %v, want [shutdown]", names)
+		}
+	})
+
+	t.Run("nil Provider", func(t *testing.T) {
+		var p *core.Provider
+		ctx := context.Background()
+		_, span := p.Tracer("presettest").Start(ctx, "dropped")
+		span.End()
+		if err := p.ForceFlush(ctx); err != nil {
+			t.Errorf("ForceFlush() error = %v", err)
+		}
+		if err := p.Shutdown(ctx); err != nil {
+			t.Errorf("Shutdown() error = %v", err)
+		}
+	})
+}
@@ -0,0 +1,419 @@
+// Package procfs reads process and cgroup statistics from the proc and cgroup file systems of Linux.
+package procfs
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// userHZ is the frequency of the clock ticks /proc reports CPU times in. It is 100 on every
+// Linux architecture Go supports.
+const userHZ = 100
+
+// unlimitedV1 is the threshold above which cgroup v1 memory limits mean no limit, the kernel
+// reports the largest page aligned int64 in that case.
+const unlimitedV1 = 1 << 62
+
+// FS reads the statistics of the current process below a root directory.
+type FS struct {
+	proc string
+	// cgroup holds the directories of the cgroup of the process, or the error finding them.
+	cgroup    cgroupDirs
+	cgroupErr error
+}
+
+// cgroupDirs are the directories of a cgroup: unified for cgroup v2, else the cpu, cpuacct and
+// memory controllers of cgroup v1, empty when not mounted.
+type cgroupDirs struct {
+	unified string
+	cpu     string
+	cpuacct string
+	memory  string
+}
+
+// NewFS returns the file systems mounted below root, "/" on a live system.
+func NewFS(root string) FS {
+	if root == "" {
+		root = "/"
+	}
+	fs := FS{proc: filepath.Join(root, "proc", "self")}
+	fs.cgroup, fs.cgroupErr = findCgroup(root, fs.proc)
+	return fs
+}
+
+// Process holds the resource usage of the current process.
+type Process struct {
+	// UserTime and SystemTime are the CPU seconds spent in user and kernel mode
+	UserTime   float64
+	SystemTime float64
+	// RSS and VirtualMemory are in bytes
+	RSS           int64
+	VirtualMemory int64
+	Threads       int64
+	OpenFDs       int64
+}
+
+// Process reads /proc/self/stat, /proc/self/status and /proc/self/fd.
+func (fs FS) Process() (Process, error) {
+	var p Process
+
+	stat, err := os.ReadFile(filepath.Join(fs.proc, "stat"))
+	if err != nil {
+		return p, err
+	}
+	// The command name may contain spaces and parentheses, the fields start after the last ')'
+	i := bytes.LastIndexByte(stat, ')')
+	if i < 0 {
+		return p, fmt.Errorf("invalid %s/stat", fs.proc)
+	}
+	// utime and stime are the 14th and 15th fields, the 12th and 13th after the command name
+	fields := strings.Fields(string(stat[i+1:]))
+	if len(fields) < 13 {
+		return p, fmt.Errorf("invalid %s/stat", fs.proc)
+	}
+	utime, err := strconv.ParseUint(fields[11], 10, 64)
+	if err != nil {
+		return p, fmt.Errorf("invalid utime in %s/stat: %w", fs.proc, err)
+	}
+	stime, err := strconv.ParseUint(fields[12], 10, 64)
+	if err != nil {
+		return p, fmt.Errorf("invalid stime in %s/stat: %w", fs.proc, err)
+	}
+	p.UserTime = float64(utime) / userHZ
+	p.SystemTime = float64(stime) / userHZ
+
+	status, err := readKeyValues(filepath.Join(fs.proc, "status"), ":")
+	if err != nil {
+		return p, err
+	}
+	p.RSS = parseKB(status["VmRSS"])
+	p.VirtualMemory = parseKB(status["VmSize"])
+	p.Threads, _ = strconv.ParseInt(status["Threads"], 10, 64)
+
+	p.OpenFDs, err = countFDs(filepath.Join(fs.proc, "fd"))
+	return p, err
+}
+
+// countFDs counts the entries of /proc/self/fd, leaving out the descriptor opened to list them.
+func countFDs(dir string) (int64, error) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	names, err := f.Readdirnames(-1)
+	if err != nil {
+		return 0, err
+	}
+	self := strconv.FormatUint(uint64(f.Fd()), 10)
+	count := int64(0)
+	for _, name := range names {
+		if name != self {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// Cgroup holds the resource usage and limits of the cgroup of the process. Limits are 0 when
+// unlimited, the usage is only set when the Has fields report it was read.
+type Cgroup struct {
+	// CPUTime is the CPU seconds used by the cgroup
+	CPUTime    float64
+	HasCPUTime bool
+	// ThrottledPeriods is the number of enforcement periods the cgroup was throttled in,
+	// for a ThrottledTime in seconds
+	ThrottledPeriods int64
+	ThrottledTime    float64
+	HasThrottling    bool
+	// CPULimit is the quota in CPUs, e.g. 1.5
+	CPULimit float64
+	// MemoryUsage and MemoryLimit are in bytes
+	MemoryUsage    int64
+	HasMemoryUsage bool
+	MemoryLimit    int64
+}
+
+// Cgroup reads the statistics of the cgroup of the process, either from the cgroup v2 unified
+// hierarchy or from the cpu, cpuacct and memory v1 controllers. Statistics whose file is
+// missing, e.g. memory.current in the root cgroup, are left out.
+// The error wraps os.ErrNotExist when no cgroup file system is mounted.
+func (fs FS) Cgroup() (Cgroup, error) {
+	if fs.cgroupErr != nil {
+		return Cgroup{}, fs.cgroupErr
+	}
+	if fs.cgroup.unified != "" {
+		return fs.cgroupV2()
+	}
+	return fs.cgroupV1()
+}
+
+func (fs FS) cgroupV2() (Cgroup, error) {
+	var c Cgroup
+	dir := fs.cgroup.unified
+
+	stat, err := readKeyValues(filepath.Join(dir, "cpu.stat"), " ")
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return c, err
+	}
+	if usage, ok := stat["usage_usec"]; ok {
+		c.CPUTime, c.HasCPUTime = parseFloat(usage)/1e6, true
+	}
+	// The throttling statistics are only listed when the cpu controller is enabled
+	if periods, ok := stat["nr_throttled"]; ok {
+		c.ThrottledPeriods, _ = strconv.ParseInt(periods, 10, 64)
+		c.ThrottledTime = parseFloat(stat["throttled_usec"]) / 1e6
+		c.HasThrottling = true
+	}
+
+	// cpu.max is "$MAX $PERIOD", with "max" as quota when unlimited
+	if max, err := readString(filepath.Join(dir, "cpu.max")); err == nil {
+		quota, period, _ := strings.Cut(max, " ")
+		if quota != "max" && parseFloat(period) > 0 {
+			c.CPULimit = parseFloat(quota) / parseFloat(period)
+		}
+	}
+
+	c.MemoryUsage, c.HasMemoryUsage = readInt(filepath.Join(dir, "memory.current"))
+	// memory.max holds "max" when unlimited
+	c.MemoryLimit, _ = readInt(filepath.Join(dir, "memory.max"))
+	return c, nil
+}
+
+func (fs FS) cgroupV1() (Cgroup, error) {
+	var c Cgroup
+	dirs := fs.cgroup
+
+	if dirs.cpu != "" {
+		stat, err := readKeyValues(filepath.Join(dirs.cpu, "cpu.stat"), " ")
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return c, err
+		}
+		if periods, ok := stat["nr_throttled"]; ok {
+			c.ThrottledPeriods, _ = strconv.ParseInt(periods, 10, 64)
+			c.ThrottledTime = parseFloat(stat["throttled_time"]) / 1e9
+			c.HasThrottling = true
+		}
+
+		// The quota is -1 when unlimited
+		quota, _ := readInt(filepath.Join(dirs.cpu, "cpu.cfs_quota_us"))
+		period, _ := readInt(filepath.Join(dirs.cpu, "cpu.cfs_period_us"))
+		if quota > 0 && period > 0 {
+			c.CPULimit = float64(quota) / float64(period)
+		}
+	}
+
+	if dirs.cpuacct != "" {
+		usage, ok := readInt(filepath.Join(dirs.cpuacct, "cpuacct.usage"))
+		c.CPUTime, c.HasCPUTime = float64(usage)/1e9, ok
+	}
+
+	if dirs.memory != "" {
+		c.MemoryUsage, c.HasMemoryUsage = readInt(filepath.Join(dirs.memory, "memory.usage_in_bytes"))
+		if limit, _ := readInt(filepath.Join(dirs.memory, "memory.limit_in_bytes")); limit < unlimitedV1 {
+			c.MemoryLimit = limit
+		}
+	}
+	return c, nil
+}
+
+// findCgroup returns the directories of the cgroup of the process. The cgroup is read from
+// /proc/self/cgroup and located below the cgroup mounts of /proc/self/mountinfo, as the
+// process may not see its own cgroup as the root of the hierarchy, e.g. with cgroupns=host.
+// Without those files, the hierarchy mounted at /sys/fs/cgroup is assumed to be the cgroup
+// of the process, as in containers. cgroup v1 is preferred on hybrid systems, where the v2
+// hierarchy usually has no controllers.
+func findCgroup(root, proc string) (cgroupDirs, error) {
+	paths, err := readCgroupPaths(filepath.Join(proc, "cgroup"))
+	if err != nil {
+		return defaultCgroup(filepath.Join(root, "sys", "fs", "cgroup"))
+	}
+	mounts, err := readCgroupMounts(filepath.Join(proc, "mountinfo"))
+	if err != nil {
+		return defaultCgroup(filepath.Join(root, "sys", "fs", "cgroup"))
+	}
+
+	var dirs cgroupDirs
+	for _, m := range mounts {
+		if m.unified {
+			continue
+		}
+		for _, controller := range m.controllers {
+			var dir *string
+			switch controller {
+			case "cpu":
+				dir = &dirs.cpu
+			case "cpuacct":
+				dir = &dirs.cpuacct
+			case "memory":
+				dir = &dirs.memory
+			default:
+				continue
+			}
+			if path, ok := paths[controller]; ok && *dir == "" {
+				*dir = m.dir(root, path)
+			}
+		}
+	}
+	if dirs.cpu != "" || dirs.cpuacct != "" || dirs.memory != "" {
+		return dirs, nil
+	}
+
+	if path, ok := paths[""]; ok {
+		for _, m := range mounts {
+			if m.unified {
+				if dirs.unified = m.dir(root, path); dirs.unified != "" {
+					return dirs, nil
+				}
+			}
+		}
+	}
+	return dirs, fmt.Errorf("no cgroup of the process below the mounts of %s: %w", proc, os.ErrNotExist)
+}
+
+// defaultCgroup returns the directories of the hierarchy mounted at dir.
+func defaultCgroup(dir string) (cgroupDirs, error) {
+	if _, err := os.Stat(filepath.Join(dir, "cgroup.controllers")); err == nil {
+		return cgroupDirs{unified: dir}, nil
+	}
+	if _, err := os.Stat(dir); err != nil {
+		return cgroupDirs{}, err
+	}
+	return cgroupDirs{
+		cpu:     filepath.Join(dir, "cpu"),
+		cpuacct: filepath.Join(dir, "cpuacct"),
+		memory:  filepath.Join(dir, "memory"),
+	}, nil
+}
+
+// readCgroupPaths reads the "hierarchy-ID:controllers:path" lines of /proc/self/cgroup into
+// the path of each controller, "" being the controller of the v2 unified hierarchy.
+func readCgroupPaths(path string) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	paths := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+		fields := strings.SplitN(line, ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		if fields[0] == "0" && fields[1] == "" {
+			paths[""] = fields[2]
+			continue
+		}
+		for _, controller := range strings.Split(fields[1], ",") {
+			paths[controller] = fields[2]
+		}
+	}
+	return paths, nil
+}
+
+// cgroupMount is a cgroup file system of /proc/self/mountinfo.
+type cgroupMount struct {
+	// root is the cgroup mounted, e.g. "/docker/<id>" when a container only sees its own
+	root  string
+	point string
+	// unified is set for the v2 hierarchy, controllers for the v1 hierarchies
+	unified     bool
+	controllers []string
+}
+
+// dir returns the directory of the cgroup at path below the mount, "" when it is not
+// visible there.
+func (m cgroupMount) dir(root, path string) string {
+	rel, err := filepath.Rel(m.root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+		return ""
+	}
+	return filepath.Join(root, m.point, rel)
+}
+
+// readCgroupMounts reads the cgroup mounts of /proc/self/mountinfo, whose lines are
+// "ID parent major:minor root point options [optional...] - type source super-options".
+func readCgroupMounts(path string) ([]cgroupMount, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var mounts []cgroupMount
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		sep := slices.Index(fields, "-")
+		if sep < 5 || len(fields) < sep+4 {
+			continue
+		}
+		m := cgroupMount{root: fields[3], point: fields[4]}
+		switch fields[sep+1] {
+		case "cgroup2":
+			m.unified = true
+		case "cgroup":
+			m.controllers = strings.Split(fields[sep+3], ",")
+		default:
+			continue
+		}
+		mounts = append(mounts, m)
+	}
+	return mounts, scanner.Err()
+}
+
+// readKeyValues reads a file of "key<sep>value" lines, such as /proc/self/status or cpu.stat.
+func readKeyValues(path, sep string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), sep)
+		if ok {
+			values[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return values, scanner.Err()
+}
+
+func readString(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// readInt returns the integer in a file, false when it is missing or does not hold an
+// integer, e.g. "max".
+func readInt(path string) (int64, bool) {
+	s, err := readString(path)
+	if err != nil {
+		return 0, false
+	}
+	i, err := strconv.ParseInt(s, 10, 64)
+	return i, err == nil
+}
+
+func parseFloat(s string) float64 {
+	f, _ := strconv.ParseFloat(s, 64)
+	return f
+}
+
+// parseKB parses the "1234 kB" values of /proc/self/status into bytes.
+func parseKB(s string) int64 {
+	kb, _ := strconv.ParseInt(strings.TrimSuffix(s, " kB"), 10, 64)
+	return kb * 1024
+}
@@ -0,0 +1,198 @@
+package procfs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestProcess(t *testing.T) {
+	p, err := NewFS("testdata/v2").Process()
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	want := Process{
+		UserTime:      12.5,
+		SystemTime:    3.75,
+		RSS:           8192 * 1024,
+		VirtualMemory: 1205632 * 1024,
+		Threads:       12,
+		OpenFDs:       3,
+	}
+	if p != want {
+		t.Errorf("Process() = %+v, want %+v", p, want)
+	}
+}
+
+func TestProcessLive(t *testing.T) {
+	if _, err := os.Stat("/proc/self/fd"); err != nil {
+		t.Skip("no /proc file system")
+	}
+
+	p, err := NewFS("/").Process()
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	// Listing the directory opens one more descriptor, which Process must not count
+	fds, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := p.OpenFDs, int64(len(fds)-1); got != want {
+		t.Errorf("OpenFDs = %d, want %d", got, want)
+	}
+}
+
+func TestCgroup(t *testing.T) {
+	for _, tt := range []struct {
+		root string
+		want Cgroup
+	}{
+		{"testdata/v2", Cgroup{
+			CPUTime:          2.5,
+			HasCPUTime:       true,
+			ThrottledPeriods: 7,
+			ThrottledTime:    0.35,
+			HasThrottling:    true,
+			CPULimit:         1.5,
+			MemoryUsage:      104857600,
+			HasMemoryUsage:   true,
+			MemoryLimit:      536870912,
+		}},
+		{"testdata/v2-unlimited", Cgroup{
+			CPUTime:        1,
+			HasCPUTime:     true,
+			HasThrottling:  true,
+			MemoryUsage:    52428800,
+			HasMemoryUsage: true,
+		}},
+		// The cgroup of the process is found below the mount of the whole hierarchy
+		{"testdata/v2-nested", Cgroup{
+			CPUTime:          4,
+			HasCPUTime:       true,
+			ThrottledPeriods: 2,
+			ThrottledTime:    0.1,
+			HasThrottling:    true,
+			CPULimit:         2,
+			MemoryUsage:      73400320,
+			HasMemoryUsage:   true,
+			MemoryLimit:      134217728,
+		}},
+		// The root cgroup has neither throttling statistics nor memory.current
+		{"testdata/v2-root", Cgroup{
+			CPUTime:    3,
+			HasCPUTime: true,
+		}},
+		{"testdata/v1", Cgroup{
+			CPUTime:          7.5,
+			HasCPUTime:       true,
+			ThrottledPeriods: 11,
+			ThrottledTime:    2.5,
+			HasThrottling:    true,
+			CPULimit:         0.5,
+			MemoryUsage:      209715200,
+			HasMemoryUsage:   true,
+			MemoryLimit:      268435456,
+		}},
+		{"testdata/v1-unlimited", Cgroup{
+			CPUTime:        1,
+			HasCPUTime:     true,
+			HasThrottling:  true,
+			MemoryUsage:    10485760,
+			HasMemoryUsage: true,
+		}},
+		// With cgroupns=host, the controllers are mounted at the cgroup of the container
+		{"testdata/v1-hostns", Cgroup{
+			CPUTime:          3,
+			HasCPUTime:       true,
+			ThrottledPeriods: 4,
+			ThrottledTime:    1.5,
+			HasThrottling:    true,
+			CPULimit:         2.5,
+			MemoryUsage:      31457280,
+			HasMemoryUsage:   true,
+			MemoryLimit:      67108864,
+		}},
+		// v1 controllers are preferred over the v2 hierarchy of hybrid hosts
+		{"testdata/v1-hybrid", Cgroup{
+			CPUTime:        5,
+			HasCPUTime:     true,
+			HasThrottling:  true,
+			MemoryUsage:    20971520,
+			HasMemoryUsage: true,
+			MemoryLimit:    41943040,
+		}},
+	} {
+		t.Run(tt.root, func(t *testing.T) {
+			c, err := NewFS(tt.root).Cgroup()
+			if err != nil {
+				t.Fatalf("Cgroup() error = %v", err)
+			}
+			if c != tt.want {
+				t.Errorf("Cgroup() = %+v, want %+v", c, tt.want)
+			}
+		})
+	}
+}
+
+func TestCgroupLive(t *testing.T) {
+	b, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		t.Skip("no /proc/self/cgroup")
+	}
+
+	fs := NewFS("/")
+	if _, err := fs.Cgroup(); err != nil {
+		t.Skipf("no cgroup file system: %v", err)
+	}
+	// Every directory found is the one of the process, named by its path in /proc/self/cgroup
+	for _, dir := range []string{fs.cgroup.unified, fs.cgroup.cpu, fs.cgroup.cpuacct, fs.cgroup.memory} {
+		if dir == "" {
+			continue
+		}
+		if _, err := os.Stat(dir); err != nil {
+			t.Errorf("cgroup directory %s: %v, /proc/self/cgroup:\n%s", dir, err, b)
+		}
+	}
+}
+
+func TestCgroupV1MemoryLimitThreshold(t *testing.T) {
+	for limit, want := range map[int64]int64{
+		unlimitedV1 - 4096: unlimitedV1 - 4096,
+		unlimitedV1:        0,
+		1<<63 - 4096:       0,
+	} {
+		root := t.TempDir()
+		for path, content := range map[string]string{
+			"cpu/cpu.stat":                 "nr_throttled 0\n",
+			"memory/memory.limit_in_bytes": strconv.FormatInt(limit, 10) + "\n",
+		} {
+			path = filepath.Join(root, "sys", "fs", "cgroup", path)
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		c, err := NewFS(root).cgroupV1()
+		if err != nil {
+			t.Fatalf("cgroupV1() error = %v", err)
+		}
+		if c.MemoryLimit != want {
+			t.Errorf("limit %d: MemoryLimit = %d, want %d", limit, c.MemoryLimit, want)
+		}
+	}
+}
+
+func TestCgroupMissing(t *testing.T) {
+	for _, root := range []string{t.TempDir(), "testdata/v2-hidden"} {
+		if _, err := NewFS(root).Cgroup(); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s: Cgroup() error = %v, want os.ErrNotExist", root, err)
+		}
+	}
+}
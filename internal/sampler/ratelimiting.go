@@ -0,0 +1,64 @@
+// Package sampler implements the trace samplers that are not part of the OpenTelemetry SDK.
+package sampler
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type rateLimiting struct {
+	perSecond float64
+	capacity  float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// RateLimiting returns a sampler that samples at most perSecond spans per second using a
+// token bucket that allows bursts of up to one second worth of spans.
+func RateLimiting(perSecond float64) sdktrace.Sampler {
+	capacity := math.Max(perSecond, 1)
+	return &rateLimiting{
+		perSecond: perSecond,
+		capacity:  capacity,
+		tokens:    capacity,
+		last:      time.Now(),
+	}
+}
+
+func (s *rateLimiting) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	decision := sdktrace.Drop
+	if s.take() {
+		decision = sdktrace.RecordAndSample
+	}
+	return sdktrace.SamplingResult{
+		Decision:   decision,
+		Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState(),
+	}
+}
+
+// take refills the bucket for the time passed since the last call and takes one token if available.
+func (s *rateLimiting) take() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.tokens = math.Min(s.capacity, s.tokens+now.Sub(s.last).Seconds()*s.perSecond)
+	s.last = now
+
+	if s.tokens < 1 {
+		return false
+	}
+	s.tokens--
+	return true
+}
+
+func (s *rateLimiting) Description() string {
+	return fmt.Sprintf("RateLimitingSampler{%g}", s.perSecond)
+}
@@ -0,0 +1,102 @@
+package sampler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// sampled returns how many of n spans s samples.
+func sampled(s sdktrace.Sampler, n int) int {
+	var count int
+	for range n {
+		if s.ShouldSample(sdktrace.SamplingParameters{ParentContext: context.Background()}).Decision == sdktrace.RecordAndSample {
+			count++
+		}
+	}
+	return count
+}
+
+// elapse moves the last refill of s back by d, as if d had passed.
+func elapse(s sdktrace.Sampler, d time.Duration) {
+	r := s.(*rateLimiting)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.last = r.last.Add(-d)
+}
+
+func TestRateLimitingBurst(t *testing.T) {
+	for _, tt := range []struct {
+		perSecond float64
+		burst     int
+	}{
+		{perSecond: 5, burst: 5},
+		{perSecond: 2.5, burst: 2},
+		// Rates below one span per second still allow a single span
+		{perSecond: 0.5, burst: 1},
+	} {
+		if got := sampled(RateLimiting(tt.perSecond), 20); got != tt.burst {
+			t.Errorf("RateLimiting(%g) sampled %d spans at once, want %d", tt.perSecond, got, tt.burst)
+		}
+	}
+}
+
+func TestRateLimitingRefill(t *testing.T) {
+	s := RateLimiting(10)
+	sampled(s, 10)
+
+	elapse(s, 300*time.Millisecond)
+	if got := sampled(s, 10); got != 3 {
+		t.Errorf("sampled %d spans 300ms after the burst, want 3", got)
+	}
+
+	// The bucket holds one second worth of spans at most
+	elapse(s, time.Minute)
+	if got := sampled(s, 100); got != 10 {
+		t.Errorf("sampled %d spans after a minute, want 10", got)
+	}
+}
+
+func TestRateLimitingBelowOnePerSecond(t *testing.T) {
+	s := RateLimiting(0.5)
+	if got := sampled(s, 1); got != 1 {
+		t.Fatalf("sampled %d spans, want the first one", got)
+	}
+
+	elapse(s, time.Second)
+	if got := sampled(s, 1); got != 0 {
+		t.Errorf("sampled %d spans after 1s, want none at 0.5/s", got)
+	}
+	elapse(s, time.Second)
+	if got := sampled(s, 1); got != 1 {
+		t.Errorf("sampled %d spans after 2s, want 1 at 0.5/s", got)
+	}
+}
+
+func TestRateLimitingKeepsTraceState(t *testing.T) {
+	state, err := trace.ParseTraceState("vendor=value")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{1},
+		TraceState: state,
+	})
+
+	result := RateLimiting(1).ShouldSample(sdktrace.SamplingParameters{
+		ParentContext: trace.ContextWithSpanContext(context.Background(), parent),
+	})
+	if result.Tracestate.String() != "vendor=value" {
+		t.Errorf("Tracestate = %q, want vendor=value", result.Tracestate.String())
+	}
+}
+
+func TestRateLimitingDescription(t *testing.T) {
+	if got := RateLimiting(2.5).Description(); got != "RateLimitingSampler{2.5}" {
+		t.Errorf("Description() = %q, want RateLimitingSampler{2.5}", got)
+	}
+}
@@ -0,0 +1,77 @@
+package lambda_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/idnandre/gobsv/internal/otlptest"
+	"github.com/idnandre/gobsv/internal/presettest"
+	"github.com/idnandre/gobsv/lambda"
+)
+
+func TestPreset(t *testing.T) {
+	presettest.Run(t, lambda.New)
+}
+
+func TestResourceDescribesFunction(t *testing.T) {
+	for key, value := range map[string]string{
+		"AWS_LAMBDA_FUNCTION_NAME":        "checkout",
+		"AWS_REGION":                      "eu-west-1",
+		"AWS_LAMBDA_FUNCTION_VERSION":     "42",
+		"AWS_LAMBDA_LOG_STREAM_NAME":      "2024/07/01/[42]abcdef",
+		"AWS_LAMBDA_FUNCTION_MEMORY_SIZE": "128",
+	} {
+		t.Setenv(key, value)
+	}
+
+	ctx := context.Background()
+	c := otlptest.NewCollector(t)
+	p, err := lambda.New(ctx, presettest.Options(c)...)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	_, span := p.Tracer("test").Start(ctx, "invocation")
+	span.End()
+	if err := p.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+
+	attrs := c.ResourceAttributes()
+	for key, want := range map[string]string{
+		"cloud.provider":  "aws",
+		"cloud.platform":  "aws_lambda",
+		"cloud.region":    "eu-west-1",
+		"faas.name":       "checkout",
+		"faas.version":    "42",
+		"faas.instance":   "2024/07/01/[42]abcdef",
+		"faas.max_memory": "134217728",
+		"service.name":    "preset-test",
+	} {
+		if got := attrs[key]; got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestWrapFlushes(t *testing.T) {
+	ctx := context.Background()
+	c := otlptest.NewCollector(t)
+	p, err := lambda.New(ctx, presettest.Options(c)...)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer p.Shutdown(ctx)
+
+	handler := lambda.Wrap(p, func(ctx context.Context, event string) (string, error) {
+		_, span := p.Tracer("test").Start(ctx, event)
+		span.End()
+		return event, nil
+	})
+	if _, err := handler(ctx, "invocation"); err != nil {
+		t.Fatal(err)
+	}
+
+	if names := c.SpanNames(); len(names) != 1 || names[0] != "invocation" {
+		t.Errorf("spans = %v, want [invocation]", names)
+	}
+}
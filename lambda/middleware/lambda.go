@@ -2,48 +2,37 @@ package middleware
 
 import (
 	"context"
+	"net/url"
 
 	"github.com/aws/aws-lambda-go/events"
+	"github.com/idnandre/gobsv/internal/httpconv"
+	"github.com/idnandre/gobsv/internal/lambdamiddleware"
 	"github.com/idnandre/gobsv/lambda"
-	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/propagation"
-	"go.opentelemetry.io/otel/trace"
 )
 
 type handlerFunc func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
 
-func TraceMiddleware(f handlerFunc) handlerFunc {
-	return func(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-		routPattern := event.Resource
-
-		newCtx := otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(event.MultiValueHeaders))
-		newCtx, span := otel.Tracer("").Start(newCtx, event.HTTPMethod+" "+routPattern, trace.WithSpanKind(trace.SpanKindServer))
-		defer lambda.ForceFlush(newCtx)
-		defer span.End()
-
-		response, err := f(newCtx, event)
-
-		queryStrings := ""
-		for key, values := range event.MultiValueQueryStringParameters {
-			for _, value := range values {
-				queryStrings += key + "=" + value + "&"
-			}
+const instrumentationName = "github.com/idnandre/gobsv/lambda/middleware"
+
+// TraceMiddleware wraps an API Gateway handler so that every invocation is traced with the
+// given provider, which is flushed before the invocation returns. Returned errors, panics and
+// 5xx responses set the span status to Error.
+func TraceMiddleware(provider *lambda.Provider, f handlerFunc) handlerFunc {
+	cfg := lambdamiddleware.NewConfig(provider, instrumentationName)
+
+	return lambda.Wrap(provider, func(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		request := httpconv.ServerRequest{
+			Method:          event.HTTPMethod,
+			Scheme:          "https",
+			Path:            event.Path,
+			Query:           url.Values(event.MultiValueQueryStringParameters).Encode(),
+			Route:           event.Resource,
+			UserAgent:       event.RequestContext.Identity.UserAgent,
+			ClientAddress:   event.RequestContext.Identity.SourceIP,
+			ProtocolVersion: httpconv.ProtocolVersion(event.RequestContext.Protocol),
 		}
+		headers := lambda.HeaderCarrier(event.Headers, event.MultiValueHeaders)
 
-		span.SetAttributes(
-			attribute.String("span.kind", "server"),
-			attribute.String("resource.name", event.HTTPMethod+" "+event.Path),
-			attribute.String("http.method", event.HTTPMethod),
-			attribute.String("http.url", routPattern),
-			attribute.String("http.raw.query", queryStrings),
-			attribute.String("http.route", routPattern),
-			attribute.String("http.target", routPattern),
-			attribute.String("http.useragent", event.RequestContext.Identity.UserAgent),
-			attribute.Int("http.status_code", response.StatusCode),
-		)
-
-		return response, err
-
-	}
+		return lambdamiddleware.Invoke(ctx, cfg, headers, request, event, f)
+	})
 }
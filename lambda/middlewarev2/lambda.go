@@ -4,39 +4,34 @@ import (
 	"context"
 
 	"github.com/aws/aws-lambda-go/events"
+	"github.com/idnandre/gobsv/internal/httpconv"
+	"github.com/idnandre/gobsv/internal/lambdamiddleware"
 	"github.com/idnandre/gobsv/lambda"
-	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/propagation"
-	"go.opentelemetry.io/otel/trace"
 )
 
 type handlerFunc func(context.Context, events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error)
 
-func TraceMiddleware(f handlerFunc) handlerFunc {
-	return func(ctx context.Context, event events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
-		routPattern := event.RouteKey
-
-		newCtx := otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(event.Headers))
-		newCtx, span := otel.Tracer("").Start(newCtx, event.RequestContext.HTTP.Method+" "+routPattern, trace.WithSpanKind(trace.SpanKindServer))
-		defer lambda.ForceFlush(newCtx)
-		defer span.End()
-
-		response, err := f(newCtx, event)
-
-		span.SetAttributes(
-			attribute.String("span.kind", "server"),
-			attribute.String("resource.name", event.RequestContext.HTTP.Method+" "+event.RawPath),
-			attribute.String("http.method", event.RequestContext.HTTP.Method),
-			attribute.String("http.url", routPattern),
-			attribute.String("http.raw.query", event.RawQueryString),
-			attribute.String("http.route", routPattern),
-			attribute.String("http.target", routPattern),
-			attribute.String("http.useragent", event.RequestContext.HTTP.UserAgent),
-			attribute.Int("http.status_code", response.StatusCode),
-		)
-
-		return response, err
-
-	}
+const instrumentationName = "github.com/idnandre/gobsv/lambda/middlewarev2"
+
+// TraceMiddleware wraps an API Gateway handler so that every invocation is traced with the
+// given provider, which is flushed before the invocation returns. Returned errors, panics and
+// 5xx responses set the span status to Error.
+func TraceMiddleware(provider *lambda.Provider, f handlerFunc) handlerFunc {
+	cfg := lambdamiddleware.NewConfig(provider, instrumentationName)
+
+	return lambda.Wrap(provider, func(ctx context.Context, event events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
+		request := httpconv.ServerRequest{
+			Method:          event.RequestContext.HTTP.Method,
+			Scheme:          "https",
+			Path:            event.RawPath,
+			Query:           event.RawQueryString,
+			Route:           event.RouteKey,
+			UserAgent:       event.RequestContext.HTTP.UserAgent,
+			ClientAddress:   event.RequestContext.HTTP.SourceIP,
+			ProtocolVersion: httpconv.ProtocolVersion(event.RequestContext.HTTP.Protocol),
+		}
+		headers := lambda.HeaderCarrier(event.Headers, nil)
+
+		return lambdamiddleware.Invoke(ctx, cfg, headers, request, event, f)
+	})
 }
@@ -0,0 +1,26 @@
+package lambda
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// HeaderCarrier returns a carrier over the headers of an API Gateway or ALB event for
+// extracting trace context. API Gateway passes header names as sent by the client, often
+// lowercased, while propagators look some of them up in canonical form, e.g. X-Amzn-Trace-Id,
+// so the names are canonicalized. Either argument may be nil.
+func HeaderCarrier(headers map[string]string, multiValueHeaders map[string][]string) propagation.HeaderCarrier {
+	carrier := make(http.Header, len(multiValueHeaders)+len(headers))
+	for key, values := range multiValueHeaders {
+		for _, value := range values {
+			carrier.Add(key, value)
+		}
+	}
+	for key, value := range headers {
+		if carrier.Get(key) == "" {
+			carrier.Set(key, value)
+		}
+	}
+	return propagation.HeaderCarrier(carrier)
+}
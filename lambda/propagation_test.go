@@ -0,0 +1,69 @@
+package lambda_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/idnandre/gobsv/lambda"
+	"go.opentelemetry.io/contrib/propagators/aws/xray"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestHeaderCarrier(t *testing.T) {
+	const (
+		traceID    = "4bf92f3577b34da6a3ce929d0e0e4736"
+		spanID     = "00f067aa0ba902b7"
+		otherSpan  = "b7ad6b7169203331"
+		xrayHeader = "Root=1-4bf92f35-77b34da6a3ce929d0e0e4736;Parent=" + spanID + ";Sampled=1"
+	)
+	propagator := propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, xray.Propagator{})
+
+	for _, tt := range []struct {
+		name              string
+		headers           map[string]string
+		multiValueHeaders map[string][]string
+		spanID            string
+	}{
+		{
+			// X-Ray looks up X-Amzn-Trace-Id in canonical form
+			name:    "lowercased X-Ray header",
+			headers: map[string]string{"x-amzn-trace-id": xrayHeader},
+			spanID:  spanID,
+		},
+		{
+			name:    "uppercased traceparent",
+			headers: map[string]string{"TRACEPARENT": "00-" + traceID + "-" + spanID + "-01"},
+			spanID:  spanID,
+		},
+		{
+			name:              "multi-value headers only",
+			multiValueHeaders: map[string][]string{"x-amzn-trace-id": {xrayHeader}},
+			spanID:            spanID,
+		},
+		{
+			name:              "multi-value headers first",
+			headers:           map[string]string{"traceparent": "00-" + traceID + "-" + otherSpan + "-01"},
+			multiValueHeaders: map[string][]string{"traceparent": {"00-" + traceID + "-" + spanID + "-01"}},
+			spanID:            spanID,
+		},
+		{
+			name: "no headers",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			carrier := lambda.HeaderCarrier(tt.headers, tt.multiValueHeaders)
+			sc := trace.SpanContextFromContext(propagator.Extract(context.Background(), carrier))
+			if tt.spanID == "" {
+				if sc.IsValid() {
+					t.Errorf("extracted %v, want nothing", sc)
+				}
+				return
+			}
+			if sc.TraceID().String() != traceID || sc.SpanID().String() != tt.spanID || !sc.IsSampled() {
+				t.Errorf("extracted %s/%s sampled %v, want %s/%s sampled",
+					sc.TraceID(), sc.SpanID(), sc.IsSampled(), traceID, tt.spanID)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package lambda
+
+import (
+	"context"
+	"os"
+	"strconv"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// detector describes the function from the environment variables set by the Lambda runtime.
+type detector struct{}
+
+func (detector) Detect(context.Context) (*resource.Resource, error) {
+	name := os.Getenv("AWS_LAMBDA_FUNCTION_NAME")
+	if name == "" {
+		return resource.Empty(), nil
+	}
+
+	attrs := []attribute.KeyValue{
+		semconv.CloudProviderAWS,
+		semconv.CloudPlatformAWSLambda,
+		semconv.FaaSName(name),
+	}
+	if v := os.Getenv("AWS_REGION"); v != "" {
+		attrs = append(attrs, semconv.CloudRegion(v))
+	}
+	if v := os.Getenv("AWS_LAMBDA_FUNCTION_VERSION"); v != "" {
+		attrs = append(attrs, semconv.FaaSVersion(v))
+	}
+	if v := os.Getenv("AWS_LAMBDA_LOG_STREAM_NAME"); v != "" {
+		attrs = append(attrs, semconv.FaaSInstance(v))
+	}
+	if mb, err := strconv.Atoi(os.Getenv("AWS_LAMBDA_FUNCTION_MEMORY_SIZE")); err == nil {
+		attrs = append(attrs, semconv.FaaSMaxMemory(mb*1024*1024))
+	}
+
+	return resource.NewWithAttributes(semconv.SchemaURL, attrs...), nil
+}